@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Future is the result of an asynchronous call
+type Future struct {
+	// Request which was made
+	Request Request
+	// Response the result is decoded into
+	Response interface{}
+
+	err    error
+	done   chan bool
+	cancel context.CancelFunc
+}
+
+// Done is closed when the call finishes
+func (f *Future) Done() <-chan bool {
+	return f.done
+}
+
+// Wait blocks until the call finishes and returns its error
+func (f *Future) Wait() error {
+	<-f.done
+	return f.err
+}
+
+// Cancel the call. Wait returns the error of the cancelled call.
+func (f *Future) Cancel() {
+	f.cancel()
+}
+
+// CallAsync makes the call in the background and returns a future for the result.
+// The response must not be read until the call is done.
+func CallAsync(ctx context.Context, c Client, req Request, rsp interface{}, opts ...CallOption) *Future {
+	ctx, cancel := context.WithCancel(ctx)
+
+	f := &Future{
+		Request:  req,
+		Response: rsp,
+		done:     make(chan bool),
+		cancel:   cancel,
+	}
+
+	go func() {
+		defer cancel()
+		f.err = c.Call(ctx, req, rsp, opts...)
+		close(f.done)
+	}()
+
+	return f
+}
+
+// Call is a single call made by CallAll
+type Call struct {
+	Request  Request
+	Response interface{}
+	// Options for this call only
+	Options []CallOption
+	// Error is set once the call is done
+	Error error
+}
+
+// CallErrors is returned by CallAll when any of the calls fail
+type CallErrors []*Call
+
+func (e CallErrors) Error() string {
+	errs := make([]string, 0, len(e))
+	for _, c := range e {
+		errs = append(errs, fmt.Sprintf("%s %s: %v", c.Request.App(), c.Request.Endpoint(), c.Error))
+	}
+	return fmt.Sprintf("%d calls failed: %s", len(e), strings.Join(errs, "; "))
+}
+
+// CallAll makes the calls concurrently and waits for them all to finish. The
+// calls share the deadline of the context. The error of each call is set on
+// the call and the failed calls are returned as CallErrors.
+func CallAll(ctx context.Context, c Client, calls []*Call, opts ...CallOption) error {
+	var wg sync.WaitGroup
+
+	for _, call := range calls {
+		wg.Add(1)
+
+		go func(call *Call) {
+			defer wg.Done()
+
+			copts := append(append([]CallOption{}, opts...), call.Options...)
+			call.Error = c.Call(ctx, call.Request, call.Response, copts...)
+		}(call)
+	}
+
+	wg.Wait()
+
+	var errs CallErrors
+
+	for _, call := range calls {
+		if call.Error != nil {
+			errs = append(errs, call)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
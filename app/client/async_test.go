@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClient echoes the endpoint into the response after a delay
+type testClient struct {
+	Client
+	delay time.Duration
+}
+
+func (c *testClient) Call(ctx context.Context, req Request, rsp interface{}, opts ...CallOption) error {
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if req.Endpoint() == "Test.Fail" {
+		return errors.New("failed")
+	}
+
+	*rsp.(*string) = req.Endpoint()
+	return nil
+}
+
+func TestCallAsync(t *testing.T) {
+	c := &testClient{delay: time.Millisecond * 10}
+
+	var rsp string
+	f := CallAsync(context.TODO(), c, newRequest("test", "Test.Call", nil, "application/json"), &rsp)
+
+	select {
+	case <-f.Done():
+	case <-time.After(time.Second):
+		t.Fatal("call not done")
+	}
+
+	if err := f.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if rsp != "Test.Call" {
+		t.Fatalf("Expected response Test.Call got %q", rsp)
+	}
+
+	// cancelled calls return the context error
+	c.delay = time.Minute
+	f = CallAsync(context.TODO(), c, newRequest("test", "Test.Call", nil, "application/json"), &rsp)
+	f.Cancel()
+
+	if err := f.Wait(); err != context.Canceled {
+		t.Fatalf("Expected cancelled got %v", err)
+	}
+}
+
+func TestCallAll(t *testing.T) {
+	c := &testClient{delay: time.Millisecond * 10}
+
+	var a, b, fail string
+
+	calls := []*Call{
+		{Request: newRequest("a", "A.Call", nil, "application/json"), Response: &a},
+		{Request: newRequest("b", "B.Call", nil, "application/json"), Response: &b},
+		{Request: newRequest("c", "Test.Fail", nil, "application/json"), Response: &fail},
+	}
+
+	start := time.Now()
+
+	err := CallAll(context.TODO(), c, calls)
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Fatalf("Expected calls to be concurrent took %v", d)
+	}
+
+	errs, ok := err.(CallErrors)
+	if !ok || len(errs) != 1 || errs[0] != calls[2] {
+		t.Fatalf("Expected the failed call to be returned got %v", err)
+	}
+	if a != "A.Call" || b != "B.Call" || calls[0].Error != nil {
+		t.Fatalf("Unexpected responses %q %q", a, b)
+	}
+
+	// the calls share the deadline
+	c.delay = time.Minute
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Millisecond*10)
+	defer cancel()
+
+	calls = calls[:2]
+	if err := CallAll(ctx, c, calls); err == nil {
+		t.Fatal("Expected deadline error")
+	}
+	for _, call := range calls {
+		if call.Error != context.DeadlineExceeded {
+			t.Fatalf("Expected deadline exceeded got %v", call.Error)
+		}
+	}
+}
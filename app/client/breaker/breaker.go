@@ -0,0 +1,318 @@
+// Package breaker provides a per node circuit breaker for the client
+//
+//	b := breaker.New(breaker.Failures(5), breaker.Timeout(time.Second*10))
+//
+//	c := rpc.NewClient(
+//		client.WrapCall(b.Wrap),
+//		client.Selector(b.Selector(new(router.RoundRobin))),
+//	)
+package breaker
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/router"
+)
+
+var (
+	// DefaultFailures is the number of consecutive failures which open a circuit
+	DefaultFailures = 5
+	// DefaultSuccesses is the number of consecutive successes which close a half open circuit
+	DefaultSuccesses = 1
+	// DefaultTimeout is how long a circuit stays open before a trial request is allowed
+	DefaultTimeout = time.Second * 10
+)
+
+// State of a circuit
+type State int
+
+const (
+	// Closed lets requests through
+	Closed State = iota
+	// Open rejects requests
+	Open
+	// HalfOpen lets a single trial request through
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+type Options struct {
+	// Failures is the number of consecutive failures which open a circuit
+	Failures int
+	// Successes is the number of consecutive successes which close a half open circuit
+	Successes int
+	// Timeout is how long a circuit stays open before a trial request is allowed
+	Timeout time.Duration
+	// Failure determines whether an error counts as a failure
+	Failure func(error) bool
+}
+
+type Option func(*Options)
+
+// Failures sets the number of consecutive failures which open a circuit
+func Failures(n int) Option {
+	return func(o *Options) {
+		o.Failures = n
+	}
+}
+
+// Successes sets the number of consecutive successes which close a half open circuit
+func Successes(n int) Option {
+	return func(o *Options) {
+		o.Successes = n
+	}
+}
+
+// Timeout sets how long a circuit stays open before a trial request is allowed
+func Timeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = t
+	}
+}
+
+// Failure sets the func which determines whether an error counts as a failure
+func Failure(fn func(error) bool) Option {
+	return func(o *Options) {
+		o.Failure = fn
+	}
+}
+
+// IsFailure is the default failure func. Network errors, timeouts and
+// server errors are failures. Errors caused by the request are not.
+func IsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch errors.FromError(err).Code {
+	case 0, http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// circuit is the state of a single node
+type circuit struct {
+	state     State
+	failures  int
+	successes int
+	opened    time.Time
+	// a trial request is in flight
+	trial bool
+}
+
+// Breaker tracks a circuit per node address
+type Breaker struct {
+	opts Options
+
+	sync.Mutex
+	circuits map[string]*circuit
+}
+
+// New returns a new breaker
+func New(opts ...Option) *Breaker {
+	options := Options{
+		Failures:  DefaultFailures,
+		Successes: DefaultSuccesses,
+		Timeout:   DefaultTimeout,
+		Failure:   IsFailure,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Breaker{
+		opts:     options,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// State returns the state of the circuit for the address
+func (b *Breaker) State(addr string) State {
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.circuits[addr]
+	if !ok {
+		return Closed
+	}
+
+	b.update(c)
+	return c.state
+}
+
+// update moves an open circuit to half open once the timeout has passed.
+// The caller must hold the lock.
+func (b *Breaker) update(c *circuit) {
+	if c.state == Open && time.Since(c.opened) >= b.opts.Timeout {
+		c.state = HalfOpen
+		c.successes = 0
+		c.trial = false
+	}
+}
+
+// available returns true if a request to the address would be allowed.
+// The caller must hold the lock.
+func (b *Breaker) available(addr string) bool {
+	c, ok := b.circuits[addr]
+	if !ok {
+		return true
+	}
+
+	b.update(c)
+
+	switch c.state {
+	case Open:
+		return false
+	case HalfOpen:
+		return !c.trial
+	}
+
+	return true
+}
+
+// Allow returns true if a request to the address is allowed. In the
+// half open state only a single trial request is allowed at a time.
+// Every allowed request must be followed by a call to Done.
+func (b *Breaker) Allow(addr string) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.available(addr) {
+		return false
+	}
+
+	if c, ok := b.circuits[addr]; ok && c.state == HalfOpen {
+		c.trial = true
+	}
+
+	return true
+}
+
+// Done records the result of a request to the address
+func (b *Breaker) Done(addr string, err error) {
+	failed := b.opts.Failure(err)
+
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.circuits[addr]
+	if !ok {
+		// nothing to track for a healthy node
+		if !failed {
+			return
+		}
+		c = &circuit{}
+		b.circuits[addr] = c
+	}
+
+	b.update(c)
+
+	switch c.state {
+	case Closed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= b.opts.Failures {
+			c.state = Open
+			c.opened = time.Now()
+		}
+	case HalfOpen:
+		c.trial = false
+		if failed {
+			c.state = Open
+			c.opened = time.Now()
+			return
+		}
+		c.successes++
+		if c.successes >= b.opts.Successes {
+			delete(b.circuits, addr)
+		}
+	}
+}
+
+// Wrap is a client.CallWrapper which rejects calls to nodes with an
+// open circuit and records the result of every other call
+func (b *Breaker) Wrap(fn client.CallFunc) client.CallFunc {
+	return func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+		if !b.Allow(addr) {
+			return errors.AppUnavailable("nitro", "circuit open for %s", addr)
+		}
+
+		err := fn(ctx, addr, req, rsp, opts)
+
+		// a call cancelled by the caller e.g a hedged request which
+		// lost says nothing about the node so it's not recorded
+		if ctx.Err() == context.Canceled {
+			b.release(addr)
+			return err
+		}
+
+		b.Done(addr, err)
+		return err
+	}
+}
+
+// release the trial of a half open circuit without recording a result
+func (b *Breaker) release(addr string) {
+	b.Lock()
+	defer b.Unlock()
+
+	if c, ok := b.circuits[addr]; ok && c.state == HalfOpen {
+		c.trial = false
+	}
+}
+
+// Selector wraps a selector so that nodes with an open circuit are skipped
+func (b *Breaker) Selector(s router.Selector) router.Selector {
+	return &selector{b: b, s: s}
+}
+
+type selector struct {
+	b *Breaker
+	s router.Selector
+}
+
+func (s *selector) Select(routes []string, opts ...router.SelectOption) (router.Next, error) {
+	if len(routes) == 0 {
+		return nil, router.ErrNoneAvailable
+	}
+
+	available := make([]string, 0, len(routes))
+
+	s.b.Lock()
+	for _, addr := range routes {
+		if s.b.available(addr) {
+			available = append(available, addr)
+		}
+	}
+	s.b.Unlock()
+
+	if len(available) == 0 {
+		return nil, errors.AppUnavailable("nitro", "circuit open for all %d nodes", len(routes))
+	}
+
+	return s.s.Select(available, opts...)
+}
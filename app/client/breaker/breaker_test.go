@@ -0,0 +1,142 @@
+package breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/router"
+)
+
+func TestBreaker(t *testing.T) {
+	b := New(Failures(2), Successes(2), Timeout(time.Millisecond*20))
+
+	var fail bool
+	calls := 0
+
+	fn := b.Wrap(func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+		calls++
+		if fail {
+			return errors.InternalServerError("test", "failed")
+		}
+		return nil
+	})
+
+	call := func() error {
+		return fn(context.TODO(), "10.0.0.1:8080", nil, nil, client.CallOptions{})
+	}
+
+	// client errors do not count
+	b.Done("10.0.0.1:8080", errors.BadRequest("test", "bad"))
+	if s := b.State("10.0.0.1:8080"); s != Closed {
+		t.Fatalf("Expected closed got %v", s)
+	}
+
+	fail = true
+	for i := 0; i < 2; i++ {
+		if err := call(); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	if s := b.State("10.0.0.1:8080"); s != Open {
+		t.Fatalf("Expected open got %v", s)
+	}
+
+	// open circuits reject without calling
+	err := call()
+	if errors.FromError(err).Code != 503 || calls != 2 {
+		t.Fatalf("Expected unavailable without a call got %v after %d calls", err, calls)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+
+	if s := b.State("10.0.0.1:8080"); s != HalfOpen {
+		t.Fatalf("Expected half open got %v", s)
+	}
+
+	// a failed trial opens the circuit again
+	if err := call(); err == nil || calls != 3 {
+		t.Fatalf("Expected trial call to fail got %v after %d calls", err, calls)
+	}
+	if s := b.State("10.0.0.1:8080"); s != Open {
+		t.Fatalf("Expected open got %v", s)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+
+	// only a single trial is allowed at a time
+	if !b.Allow("10.0.0.1:8080") {
+		t.Fatal("Expected trial to be allowed")
+	}
+	if b.Allow("10.0.0.1:8080") {
+		t.Fatal("Expected second trial to be rejected")
+	}
+	b.Done("10.0.0.1:8080", nil)
+
+	fail = false
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.State("10.0.0.1:8080"); s != Closed {
+		t.Fatalf("Expected closed got %v", s)
+	}
+}
+
+func TestBreakerCancelled(t *testing.T) {
+	b := New(Failures(1))
+
+	fn := b.Wrap(func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+		<-ctx.Done()
+		return errors.Timeout("test", "cancelled")
+	})
+
+	// a cancelled call such as a hedged request which lost isn't a failure
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fn(ctx, "10.0.0.1:8080", nil, nil, client.CallOptions{})
+	if s := b.State("10.0.0.1:8080"); s != Closed {
+		t.Fatalf("Expected closed got %v", s)
+	}
+
+	// a call which times out is
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	fn(ctx, "10.0.0.1:8080", nil, nil, client.CallOptions{})
+	if s := b.State("10.0.0.1:8080"); s != Open {
+		t.Fatalf("Expected open got %v", s)
+	}
+}
+
+func TestSelector(t *testing.T) {
+	b := New(Failures(1))
+	s := b.Selector(new(router.RoundRobin))
+
+	routes := []string{"10.0.0.1:8080", "10.0.0.2:8080"}
+
+	b.Done(routes[0], errors.Timeout("test", "timeout"))
+
+	next, err := s.Select(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if addr := next(); addr != routes[1] {
+			t.Fatalf("Expected %v got %v", routes[1], addr)
+		}
+	}
+
+	b.Done(routes[1], errors.Timeout("test", "timeout"))
+
+	_, err = s.Select(routes)
+	if errors.FromError(err).Code != 503 {
+		t.Fatalf("Expected unavailable got %v", err)
+	}
+	if err == router.ErrNoneAvailable {
+		t.Fatal("Expected a distinct error when all circuits are open")
+	}
+}
@@ -0,0 +1,384 @@
+// Package cache caches the responses of calls in a cache.Cache. The time a
+// response is cached for is declared by the endpoint in its metadata. Responses
+// are cached by caller as identified by the IdentityHeaders of the context.
+//
+//	s.Handle(s.NewHandler(new(Greeter),
+//		server.EndpointMetadata("Greeter.Hello", map[string]string{cache.TTLKey: "30s"}),
+//	))
+//
+//	rc := cache.New(memory.NewCache(), cache.Registry(reg))
+//	c := rpc.NewClient(client.Wrap(rc.Wrap))
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/cache"
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/codec"
+	raw "github.com/gonitro/nitro/app/codec/bytes"
+	mjson "github.com/gonitro/nitro/app/codec/json"
+	"github.com/gonitro/nitro/app/codec/jsonrpc"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/registry"
+)
+
+var (
+	// TTLKey is the endpoint metadata key for the time a response is cached e.g 30s
+	TTLKey = "cache_ttl"
+	// StaleKey is the endpoint metadata key for the time a response is served
+	// after it expires while it's refreshed in the background e.g 1m
+	StaleKey = "cache_stale"
+	// BypassHeader is the metadata key which skips the cache when set to true.
+	// The response is still cached for later calls.
+	BypassHeader = "Cache-Bypass"
+	// DefaultRefresh is how often the endpoint metadata is looked up
+	DefaultRefresh = time.Minute
+	// IdentityHeaders are the metadata keys identifying the caller. Their
+	// values are part of the cache key so responses aren't shared by callers.
+	IdentityHeaders = []string{"Authorization"}
+	// DefaultCodecs encode the request hashed in the cache key by content type
+	// when the client has no codec of its own for it
+	DefaultCodecs = map[string]codec.NewCodec{
+		"application/json":         mjson.NewCodec,
+		"application/json-rpc":     jsonrpc.NewCodec,
+		"application/octet-stream": raw.NewCodec,
+	}
+)
+
+type Options struct {
+	// Registry the endpoint metadata is looked up in
+	Registry registry.Table
+	// Stale is the default time a response is served while it's refreshed
+	Stale time.Duration
+	// Refresh is how often the endpoint metadata is looked up
+	Refresh time.Duration
+}
+
+type Option func(*Options)
+
+// Registry sets the registry the endpoint metadata is looked up in
+func Registry(r registry.Table) Option {
+	return func(o *Options) {
+		o.Registry = r
+	}
+}
+
+// Stale sets the default time an expired response is served while it's refreshed
+func Stale(d time.Duration) Option {
+	return func(o *Options) {
+		o.Stale = d
+	}
+}
+
+// Refresh sets how often the endpoint metadata is looked up
+func Refresh(d time.Duration) Option {
+	return func(o *Options) {
+		o.Refresh = d
+	}
+}
+
+// Cache caches the responses of a client
+type Cache struct {
+	opts  Options
+	store cache.Cache
+
+	sync.Mutex
+	// endpoint config by app and endpoint
+	endpoints map[string]*endpoint
+	// keys being refreshed in the background
+	refreshing map[string]bool
+	// time each key can be deleted at once expired and stale
+	expiries map[string]time.Time
+	// last time expired keys were deleted
+	swept time.Time
+}
+
+// endpoint is the cache config of an endpoint
+type endpoint struct {
+	ttl     time.Duration
+	stale   time.Duration
+	updated time.Time
+}
+
+// entry is the encoded value held in the store
+type entry struct {
+	Expires  time.Time       `json:"expires"`
+	Response json.RawMessage `json:"response"`
+}
+
+// New returns a Cache which stores responses in the given cache
+func New(store cache.Cache, opts ...Option) *Cache {
+	options := Options{
+		Refresh: DefaultRefresh,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Cache{
+		opts:       options,
+		store:      store,
+		endpoints:  make(map[string]*endpoint),
+		refreshing: make(map[string]bool),
+		expiries:   make(map[string]time.Time),
+		swept:      time.Now(),
+	}
+}
+
+// Key returns the cache key of a request made up of the app, endpoint and a
+// hash of the identity of the caller in the context and the request encoded
+// with the codec of its content type
+func Key(ctx context.Context, req client.Request, cf codec.NewCodec) (string, error) {
+	buf := new(buffer)
+
+	if err := cf(buf).Write(&codec.Message{
+		Id:       "0",
+		Type:     codec.Request,
+		Target:   req.App(),
+		Method:   req.Method(),
+		Endpoint: req.Endpoint(),
+	}, req.Body()); err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, k := range IdentityHeaders {
+		v, _ := metadata.Get(ctx, k)
+		fmt.Fprintf(h, "%s=%q\n", k, v)
+	}
+	h.Write(buf.Bytes())
+
+	return req.App() + "/" + req.Endpoint() + "/" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buffer is the io.ReadWriteCloser a request is encoded into
+type buffer struct {
+	bytes.Buffer
+}
+
+func (b *buffer) Close() error {
+	return nil
+}
+
+// endpoint returns the cache config of the endpoint, looking it up
+// in the registry when it's not known or is out of date
+func (c *Cache) endpoint(app, name string) *endpoint {
+	key := app + "/" + name
+
+	c.Lock()
+	ep, ok := c.endpoints[key]
+	c.Unlock()
+
+	if ok && time.Since(ep.updated) < c.opts.Refresh {
+		return ep
+	}
+
+	ep = &endpoint{
+		stale:   c.opts.Stale,
+		updated: time.Now(),
+	}
+
+	if c.opts.Registry != nil {
+		apps, _ := c.opts.Registry.Get(app)
+		for _, a := range apps {
+			for _, e := range a.Endpoints {
+				if e.Name != name {
+					continue
+				}
+				if d, err := time.ParseDuration(e.Metadata[TTLKey]); err == nil {
+					ep.ttl = d
+				}
+				if d, err := time.ParseDuration(e.Metadata[StaleKey]); err == nil {
+					ep.stale = d
+				}
+			}
+		}
+	}
+
+	c.Lock()
+	c.endpoints[key] = ep
+	c.Unlock()
+
+	return ep
+}
+
+func (c *Cache) get(key string) (*entry, bool) {
+	v, err := c.store.Get(key)
+	if err != nil {
+		return nil, false
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, false
+	}
+
+	e := new(entry)
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, false
+	}
+
+	return e, true
+}
+
+func (c *Cache) set(key string, rsp interface{}, ttl, stale time.Duration) error {
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(ttl)
+
+	v, err := json.Marshal(&entry{
+		Expires:  expires,
+		Response: b,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.store.Set(key, v); err != nil {
+		return err
+	}
+
+	c.Lock()
+	c.expiries[key] = expires.Add(stale)
+	c.Unlock()
+
+	c.sweep()
+
+	return nil
+}
+
+// delete the entry of the key
+func (c *Cache) delete(key string) {
+	c.store.Delete(key)
+
+	c.Lock()
+	delete(c.expiries, key)
+	c.Unlock()
+}
+
+// sweep deletes the entries which are past their expiry and stale time.
+// It runs at most once per refresh interval.
+func (c *Cache) sweep() {
+	now := time.Now()
+
+	c.Lock()
+	if now.Sub(c.swept) < c.opts.Refresh {
+		c.Unlock()
+		return
+	}
+	c.swept = now
+
+	var keys []string
+	for key, t := range c.expiries {
+		if !now.Before(t) {
+			keys = append(keys, key)
+			delete(c.expiries, key)
+		}
+	}
+	c.Unlock()
+
+	for _, key := range keys {
+		c.store.Delete(key)
+	}
+}
+
+// revalidate refreshes the cached response in the background. The call
+// keeps the metadata of the context but not its deadline.
+func (c *Cache) revalidate(ctx context.Context, cl client.Client, key string, req client.Request, rsp interface{}, ep *endpoint, opts []client.CallOption) {
+	c.Lock()
+	if c.refreshing[key] {
+		c.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.Unlock()
+
+	md, _ := metadata.FromContext(ctx)
+	ctx = metadata.NewContext(context.Background(), md)
+	rsp = reflect.New(reflect.TypeOf(rsp).Elem()).Interface()
+
+	go func() {
+		if err := cl.Call(ctx, req, rsp, opts...); err == nil {
+			c.set(key, rsp, ep.ttl, ep.stale)
+		}
+
+		c.Lock()
+		delete(c.refreshing, key)
+		c.Unlock()
+	}()
+}
+
+// Wrap is a client.Wrapper which caches the responses of calls
+func (c *Cache) Wrap(cl client.Client) client.Client {
+	return &cacheClient{Client: cl, c: c}
+}
+
+type cacheClient struct {
+	client.Client
+	c *Cache
+}
+
+func (c *cacheClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	// only responses decoded into a pointer can be cached
+	if v := reflect.ValueOf(rsp); v.Kind() != reflect.Ptr || v.IsNil() {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	ep := c.c.endpoint(req.App(), req.Endpoint())
+	if ep.ttl <= 0 {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	// the request is hashed with the codec it's sent with
+	cf, ok := c.Client.Options().Codecs[req.ContentType()]
+	if !ok {
+		cf, ok = DefaultCodecs[req.ContentType()]
+	}
+	if !ok {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	key, err := Key(ctx, req, cf)
+	if err != nil {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	if v, _ := metadata.Get(ctx, BypassHeader); v != "true" {
+		if e, ok := c.c.get(key); ok {
+			now := time.Now()
+
+			switch {
+			// serve the response while it's fresh or stale
+			case now.Before(e.Expires.Add(ep.stale)) && json.Unmarshal(e.Response, rsp) == nil:
+				if !now.Before(e.Expires) {
+					c.c.revalidate(ctx, c.Client, key, req, rsp, ep, opts)
+				}
+				return nil
+			// delete the entry once it's past being served
+			case !now.Before(e.Expires.Add(ep.stale)):
+				c.c.delete(key)
+			}
+		}
+	}
+
+	if err := c.Client.Call(ctx, req, rsp, opts...); err != nil {
+		return err
+	}
+
+	c.c.set(key, rsp, ep.ttl, ep.stale)
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/cache/memory"
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/registry"
+	regMemory "github.com/gonitro/nitro/app/registry/memory"
+)
+
+type testRequest struct {
+	client.Request
+	endpoint string
+	body     interface{}
+}
+
+func (r *testRequest) App() string {
+	return "greeter"
+}
+
+func (r *testRequest) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *testRequest) Method() string {
+	return r.endpoint
+}
+
+func (r *testRequest) ContentType() string {
+	return "application/json"
+}
+
+func (r *testRequest) Body() interface{} {
+	return r.body
+}
+
+type testResponse struct {
+	Count int32
+}
+
+// testClient responds with the number of calls made
+type testClient struct {
+	client.Client
+	calls int32
+}
+
+func (c *testClient) Options() client.Options {
+	return client.NewOptions()
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	rsp.(*testResponse).Count = atomic.AddInt32(&c.calls, 1)
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	reg := regMemory.NewTable()
+	reg.Add(&registry.App{
+		Name: "greeter",
+		Endpoints: []*registry.Endpoint{
+			{Name: "Greeter.Hello", Metadata: map[string]string{TTLKey: "50ms", StaleKey: "1s"}},
+			{Name: "Greeter.Other", Metadata: map[string]string{}},
+		},
+		Instances: []*registry.Instance{{Id: "1", Address: "10.0.0.1:8080"}},
+	})
+
+	tc := &testClient{}
+	c := New(memory.NewCache(), Registry(reg)).Wrap(tc)
+
+	call := func(ctx context.Context, endpoint, body string) int32 {
+		rsp := new(testResponse)
+		if err := c.Call(ctx, &testRequest{endpoint: endpoint, body: body}, rsp); err != nil {
+			t.Fatal(err)
+		}
+		return rsp.Count
+	}
+
+	if n := call(context.TODO(), "Greeter.Hello", "a"); n != 1 {
+		t.Fatalf("Expected call 1 got %d", n)
+	}
+	if n := call(context.TODO(), "Greeter.Hello", "a"); n != 1 {
+		t.Fatalf("Expected cached response got %d", n)
+	}
+
+	// different requests are cached separately
+	if n := call(context.TODO(), "Greeter.Hello", "b"); n != 2 {
+		t.Fatalf("Expected call 2 got %d", n)
+	}
+
+	// endpoints without a ttl aren't cached
+	call(context.TODO(), "Greeter.Other", "a")
+	if n := call(context.TODO(), "Greeter.Other", "a"); n != 4 {
+		t.Fatalf("Expected call 4 got %d", n)
+	}
+
+	// the bypass header skips the cache but updates it
+	ctx := metadata.Set(context.TODO(), BypassHeader, "true")
+	if n := call(ctx, "Greeter.Hello", "a"); n != 5 {
+		t.Fatalf("Expected call 5 got %d", n)
+	}
+	if n := call(context.TODO(), "Greeter.Hello", "a"); n != 5 {
+		t.Fatalf("Expected updated response got %d", n)
+	}
+
+	// stale responses are served while they're refreshed
+	time.Sleep(time.Millisecond * 60)
+
+	if n := call(context.TODO(), "Greeter.Hello", "a"); n != 5 {
+		t.Fatalf("Expected stale response got %d", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for call(context.TODO(), "Greeter.Hello", "a") != 6 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected response to be refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCacheIdentity(t *testing.T) {
+	reg := regMemory.NewTable()
+	reg.Add(&registry.App{
+		Name: "greeter",
+		Endpoints: []*registry.Endpoint{
+			{Name: "Greeter.Hello", Metadata: map[string]string{TTLKey: "1m"}},
+		},
+		Instances: []*registry.Instance{{Id: "1", Address: "10.0.0.1:8080"}},
+	})
+
+	tc := &testClient{}
+	c := New(memory.NewCache(), Registry(reg)).Wrap(tc)
+
+	call := func(auth string) int32 {
+		ctx := metadata.Set(context.TODO(), "Authorization", auth)
+		rsp := new(testResponse)
+		if err := c.Call(ctx, &testRequest{endpoint: "Greeter.Hello", body: "a"}, rsp); err != nil {
+			t.Fatal(err)
+		}
+		return rsp.Count
+	}
+
+	if n := call("Bearer alice"); n != 1 {
+		t.Fatalf("Expected call 1 got %d", n)
+	}
+	// another caller doesn't get the response cached for the first
+	if n := call("Bearer bob"); n != 2 {
+		t.Fatalf("Expected call 2 got %d", n)
+	}
+	if n := call("Bearer alice"); n != 1 {
+		t.Fatalf("Expected cached response got %d", n)
+	}
+}
+
+func TestCacheExpired(t *testing.T) {
+	reg := regMemory.NewTable()
+	reg.Add(&registry.App{
+		Name: "greeter",
+		Endpoints: []*registry.Endpoint{
+			{Name: "Greeter.Hello", Metadata: map[string]string{TTLKey: "10ms", StaleKey: "10ms"}},
+		},
+		Instances: []*registry.Instance{{Id: "1", Address: "10.0.0.1:8080"}},
+	})
+
+	store := memory.NewCache()
+	tc := &testClient{}
+	c := New(store, Registry(reg), Refresh(time.Millisecond*10)).Wrap(tc)
+
+	call := func(body string) {
+		if err := c.Call(context.TODO(), &testRequest{endpoint: "Greeter.Hello", body: body}, new(testResponse)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	call("a")
+	key, err := Key(context.TODO(), &testRequest{endpoint: "Greeter.Hello", body: "a"}, DefaultCodecs["application/json"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(key); err != nil {
+		t.Fatalf("Expected response to be cached got %v", err)
+	}
+
+	// caching another response deletes the entries past their stale time
+	time.Sleep(time.Millisecond * 30)
+	call("b")
+
+	if _, err := store.Get(key); err == nil {
+		t.Fatal("Expected expired response to be deleted")
+	}
+}
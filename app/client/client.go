@@ -103,4 +103,16 @@ var (
 	DefaultPoolSize = 100
 	// DefaultPoolTTL sets the connection pool ttl
 	DefaultPoolTTL = time.Minute
+	// DefaultPoolConns is the number of connections per address calls are multiplexed over
+	DefaultPoolConns = 2
+	// HedgeHeader is the metadata key set to the attempt number on hedged requests
+	HedgeHeader = "Hedge"
+	// HedgePercentile is the latency percentile of an endpoint after which a hedged request is sent
+	HedgePercentile = 0.95
+	// DeadlineMargin is the least time taken off the timeout passed to the server when
+	// the context already has a deadline so the response arrives before it expires
+	DeadlineMargin = time.Millisecond * 20
+	// DeadlineRatio is the proportion of the remaining time taken off the timeout
+	// passed to the server if it's more than the DeadlineMargin
+	DeadlineRatio = 0.1
 )
@@ -0,0 +1,435 @@
+// Package limit provides client side rate and concurrency limits per app and endpoint
+//
+//	l := limit.New(
+//		limit.Default(limit.Limit{Rate: 100, Concurrency: 10}),
+//		limit.Endpoint("greeter", "Greeter.Hello", limit.Limit{Rate: 10}),
+//		limit.Wait(time.Second),
+//	)
+//
+//	c := rpc.NewClient(client.Wrap(l.Wrap))
+package limit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/errors"
+)
+
+var (
+	// DefaultBackoff is the factor the concurrency limit is multiplied by when the app is overloaded
+	DefaultBackoff = 0.9
+)
+
+// Limit for an app or endpoint
+type Limit struct {
+	// Rate is the number of calls per second. Zero is unlimited.
+	Rate float64
+	// Burst is the number of calls allowed at once above the rate. Defaults to the rate.
+	Burst int
+	// Concurrency is the initial number of calls in flight. Zero is unlimited.
+	// The limit is increased while calls succeed and decreased on overload.
+	Concurrency int
+	// MinConcurrency is the lowest the concurrency limit goes. Defaults to 1.
+	MinConcurrency int
+	// MaxConcurrency is the highest the concurrency limit goes. Zero is unbounded.
+	MaxConcurrency int
+}
+
+type Options struct {
+	// Default limit applied to each app
+	Default Limit
+	// Apps is the limit for each app by name
+	Apps map[string]Limit
+	// Endpoints is the limit for each endpoint by app and endpoint
+	Endpoints map[string]map[string]Limit
+	// Wait is the max time a call queues for a limit. Zero rejects immediately.
+	Wait time.Duration
+	// Overload determines whether an error means the app is overloaded
+	Overload func(error) bool
+}
+
+type Option func(*Options)
+
+// Default sets the limit applied to each app
+func Default(l Limit) Option {
+	return func(o *Options) {
+		o.Default = l
+	}
+}
+
+// App sets the limit for an app
+func App(name string, l Limit) Option {
+	return func(o *Options) {
+		o.Apps[name] = l
+	}
+}
+
+// Endpoint sets the limit for an endpoint of an app
+func Endpoint(app, endpoint string, l Limit) Option {
+	return func(o *Options) {
+		if o.Endpoints[app] == nil {
+			o.Endpoints[app] = make(map[string]Limit)
+		}
+		o.Endpoints[app][endpoint] = l
+	}
+}
+
+// Wait sets the max time a call queues for a limit before being rejected
+func Wait(d time.Duration) Option {
+	return func(o *Options) {
+		o.Wait = d
+	}
+}
+
+// Overload sets the func which determines whether an error means the app is overloaded
+func Overload(fn func(error) bool) Option {
+	return func(o *Options) {
+		o.Overload = fn
+	}
+}
+
+// IsOverload is the default overload func. Timeouts and
+// unavailable or rate limited apps are overloaded.
+func IsOverload(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch errors.FromError(err).Code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// Error is returned when a call is rejected by a limit. It's
+// encoded as a 429 error so it's understood across the network.
+type Error struct {
+	App      string
+	Endpoint string
+	// Limit is rate or concurrency
+	Limit string
+}
+
+func (e *Error) Error() string {
+	return errors.TooManyRequests("nitro.limit", "%s limit exceeded for %s %s", e.Limit, e.App, e.Endpoint).Error()
+}
+
+// Status is the current state of a limit
+type Status struct {
+	App string
+	// Endpoint is empty for a limit on the whole app
+	Endpoint string
+	// Rate is the calls per second
+	Rate float64
+	// Tokens is the number of calls which can be made now
+	Tokens float64
+	// Concurrency is the current adaptive concurrency limit
+	Concurrency int
+	// InFlight is the number of calls in flight
+	InFlight int
+}
+
+// Limiter limits the calls made by a client
+type Limiter struct {
+	opts Options
+
+	sync.Mutex
+	limits map[string]*limiter
+}
+
+// limiter is the state of a single limit
+type limiter struct {
+	app      string
+	endpoint string
+	cfg      Limit
+
+	sync.Mutex
+	// token bucket
+	tokens float64
+	last   time.Time
+	// adaptive concurrency
+	limit    float64
+	inflight int
+	// closed when a call finishes
+	notify chan bool
+}
+
+// New returns a new limiter
+func New(opts ...Option) *Limiter {
+	options := Options{
+		Apps:      make(map[string]Limit),
+		Endpoints: make(map[string]map[string]Limit),
+		Overload:  IsOverload,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Limiter{
+		opts:   options,
+		limits: make(map[string]*limiter),
+	}
+}
+
+func newLimiter(app, endpoint string, cfg Limit) *limiter {
+	if cfg.Burst <= 0 {
+		cfg.Burst = int(math.Max(1, math.Ceil(cfg.Rate)))
+	}
+	if cfg.MinConcurrency <= 0 {
+		cfg.MinConcurrency = 1
+	}
+
+	return &limiter{
+		app:      app,
+		endpoint: endpoint,
+		cfg:      cfg,
+		tokens:   float64(cfg.Burst),
+		last:     time.Now(),
+		limit:    float64(cfg.Concurrency),
+		notify:   make(chan bool),
+	}
+}
+
+// get the limiter for the endpoint or nil if it's unlimited
+func (l *Limiter) get(app, endpoint string) *limiter {
+	key := app
+	cfg := l.opts.Default
+
+	if c, ok := l.opts.Endpoints[app][endpoint]; ok {
+		key = app + "/" + endpoint
+		cfg = c
+	} else if c, ok := l.opts.Apps[app]; ok {
+		cfg = c
+		endpoint = ""
+	} else {
+		endpoint = ""
+	}
+
+	if cfg.Rate <= 0 && cfg.Concurrency <= 0 {
+		return nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	lim, ok := l.limits[key]
+	if !ok {
+		lim = newLimiter(app, endpoint, cfg)
+		l.limits[key] = lim
+	}
+
+	return lim
+}
+
+// Limits returns the current state of the limits in use
+func (l *Limiter) Limits() []Status {
+	l.Lock()
+	limits := make([]*limiter, 0, len(l.limits))
+	for _, lim := range l.limits {
+		limits = append(limits, lim)
+	}
+	l.Unlock()
+
+	status := make([]Status, 0, len(limits))
+
+	for _, lim := range limits {
+		lim.Lock()
+		lim.refill(time.Now())
+		status = append(status, Status{
+			App:         lim.app,
+			Endpoint:    lim.endpoint,
+			Rate:        lim.cfg.Rate,
+			Tokens:      lim.tokens,
+			Concurrency: int(lim.limit),
+			InFlight:    lim.inflight,
+		})
+		lim.Unlock()
+	}
+
+	sort.Slice(status, func(i, j int) bool {
+		if status[i].App == status[j].App {
+			return status[i].Endpoint < status[j].Endpoint
+		}
+		return status[i].App < status[j].App
+	})
+
+	return status
+}
+
+// Wrap is a client.Wrapper which limits calls
+func (l *Limiter) Wrap(c client.Client) client.Client {
+	return &limitClient{Client: c, l: l}
+}
+
+type limitClient struct {
+	client.Client
+	l *Limiter
+}
+
+func (c *limitClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	lim := c.l.get(req.App(), req.Endpoint())
+	if lim == nil {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	// the time we're willing to queue for
+	deadline := time.Now().Add(c.l.opts.Wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if err := lim.take(ctx, deadline); err != nil {
+		// the caller gave up rather than the limit rejecting the call
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &Error{App: req.App(), Endpoint: req.Endpoint(), Limit: "rate"}
+	}
+
+	if err := lim.enter(ctx, deadline); err != nil {
+		// the call isn't made so the token isn't used
+		lim.refund()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &Error{App: req.App(), Endpoint: req.Endpoint(), Limit: "concurrency"}
+	}
+
+	err := c.Client.Call(ctx, req, rsp, opts...)
+	lim.done(c.l.opts.Overload(err), err == nil)
+	return err
+}
+
+// refill the token bucket. Called under lock.
+func (l *limiter) refill(now time.Time) {
+	if l.cfg.Rate <= 0 {
+		return
+	}
+	l.tokens = math.Min(float64(l.cfg.Burst), l.tokens+now.Sub(l.last).Seconds()*l.cfg.Rate)
+	l.last = now
+}
+
+// take a token, waiting until the deadline for one to be available
+func (l *limiter) take(ctx context.Context, deadline time.Time) error {
+	l.Lock()
+
+	if l.cfg.Rate <= 0 {
+		l.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	l.refill(now)
+
+	if l.tokens >= 1 {
+		l.tokens--
+		l.Unlock()
+		return nil
+	}
+
+	// time until the next token is available
+	delay := time.Duration((1 - l.tokens) / l.cfg.Rate * float64(time.Second))
+	if now.Add(delay).After(deadline) {
+		l.Unlock()
+		return context.DeadlineExceeded
+	}
+
+	// reserve the token so later calls queue behind this one
+	l.tokens--
+	l.Unlock()
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// give back the token
+		l.Lock()
+		l.tokens++
+		l.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refund a token taken for a call which wasn't made
+func (l *limiter) refund() {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.cfg.Rate <= 0 {
+		return
+	}
+
+	l.refill(time.Now())
+	l.tokens = math.Min(float64(l.cfg.Burst), l.tokens+1)
+}
+
+// enter waits until the deadline for the number of calls in flight to be below the limit
+func (l *limiter) enter(ctx context.Context, deadline time.Time) error {
+	for {
+		l.Lock()
+		if l.limit <= 0 || float64(l.inflight) < math.Floor(l.limit) {
+			l.inflight++
+			l.Unlock()
+			return nil
+		}
+		notify := l.notify
+		l.Unlock()
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return context.DeadlineExceeded
+		}
+
+		t := time.NewTimer(wait)
+
+		select {
+		case <-notify:
+			t.Stop()
+		case <-t.C:
+			return context.DeadlineExceeded
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// done records the end of a call and adjusts the concurrency limit. The
+// limit is increased additively on success when it's in use and decreased
+// multiplicatively on overload.
+func (l *limiter) done(overload, success bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	saturated := float64(l.inflight) >= math.Floor(l.limit)
+	l.inflight--
+
+	if l.cfg.Concurrency > 0 {
+		switch {
+		case overload:
+			l.limit = math.Max(float64(l.cfg.MinConcurrency), l.limit*DefaultBackoff)
+		case success && saturated:
+			l.limit += 1 / l.limit
+			if l.cfg.MaxConcurrency > 0 {
+				l.limit = math.Min(float64(l.cfg.MaxConcurrency), l.limit)
+			}
+		}
+	}
+
+	// wake up queued calls
+	close(l.notify)
+	l.notify = make(chan bool)
+}
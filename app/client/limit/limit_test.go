@@ -0,0 +1,179 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/errors"
+)
+
+type testRequest struct {
+	client.Request
+	app, endpoint string
+}
+
+func (r *testRequest) App() string {
+	return r.app
+}
+
+func (r *testRequest) Endpoint() string {
+	return r.endpoint
+}
+
+type testClient struct {
+	client.Client
+	delay time.Duration
+	err   error
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	time.Sleep(c.delay)
+	return c.err
+}
+
+func TestRate(t *testing.T) {
+	l := New(
+		Default(Limit{Rate: 1000}),
+		Endpoint("greeter", "Greeter.Hello", Limit{Rate: 10, Burst: 2}),
+	)
+	c := l.Wrap(&testClient{})
+
+	req := &testRequest{app: "greeter", endpoint: "Greeter.Hello"}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.TODO(), req, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the burst is used up
+	err := c.Call(context.TODO(), req, nil)
+	if lerr, ok := err.(*Error); !ok || lerr.Limit != "rate" {
+		t.Fatalf("Expected rate limit error got %v", err)
+	}
+	if errors.FromError(err).Code != 429 {
+		t.Fatalf("Expected 429 got %v", err)
+	}
+
+	// other endpoints use the app limit
+	if err := c.Call(context.TODO(), &testRequest{app: "greeter", endpoint: "Greeter.Other"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// queue for the next token
+	l.opts.Wait = time.Second
+	start := time.Now()
+	if err := c.Call(context.TODO(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < time.Millisecond*50 {
+		t.Fatalf("Expected call to wait for a token took %v", d)
+	}
+
+	status := l.Limits()
+	if len(status) != 2 || status[0].Endpoint != "" || status[1].Endpoint != "Greeter.Hello" || status[1].Rate != 10 {
+		t.Fatalf("Unexpected limits %+v", status)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tc := &testClient{delay: time.Millisecond * 50}
+	l := New(App("greeter", Limit{Concurrency: 2, MaxConcurrency: 3}))
+	c := l.Wrap(tc)
+
+	req := &testRequest{app: "greeter", endpoint: "Greeter.Hello"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Call(context.TODO(), req, nil)
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	var limited int
+	for err := range errs {
+		if lerr, ok := err.(*Error); ok && lerr.Limit == "concurrency" {
+			limited++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if limited != 1 {
+		t.Fatalf("Expected 1 call to be limited got %d", limited)
+	}
+
+	// the limit grows on success when saturated and is capped
+	if s := l.Limits()[0]; s.Concurrency != 2 || s.InFlight != 0 {
+		t.Fatalf("Unexpected limit %+v", s)
+	}
+
+	// and backs off on overload
+	tc.delay = 0
+	tc.err = errors.AppUnavailable("greeter", "overloaded")
+	for i := 0; i < 10; i++ {
+		c.Call(context.TODO(), req, nil)
+	}
+	if s := l.Limits()[0]; s.Concurrency != 1 {
+		t.Fatalf("Expected limit to back off to 1 got %+v", s)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	l := New(
+		Default(Limit{Rate: 1, Burst: 1, Concurrency: 1, MaxConcurrency: 1}),
+		Wait(time.Second),
+	)
+	c := l.Wrap(&testClient{delay: time.Millisecond * 50})
+
+	req := &testRequest{app: "greeter", endpoint: "Greeter.Hello"}
+
+	// hold the only slot
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Call(context.TODO(), req, nil)
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	// a cancelled call returns the context error rather than a limit error
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		cancel()
+	}()
+	if err := c.Call(ctx, req, nil); err != context.Canceled {
+		t.Fatalf("Expected context cancelled got %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// a call rejected by the concurrency limit gives back its token
+	l = New(Default(Limit{Rate: 1, Burst: 2, Concurrency: 1, MaxConcurrency: 1}))
+	c = l.Wrap(&testClient{delay: time.Millisecond * 50})
+
+	go func() {
+		done <- c.Call(context.TODO(), req, nil)
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	err := c.Call(context.TODO(), req, nil)
+	if lerr, ok := err.(*Error); !ok || lerr.Limit != "concurrency" {
+		t.Fatalf("Expected concurrency limit error got %v", err)
+	}
+	if tokens := l.Limits()[0].Tokens; tokens < 1 {
+		t.Fatalf("Expected the token to be refunded got %v tokens", tokens)
+	}
+
+	<-done
+}
@@ -5,6 +5,7 @@ import (
 	"sort"
 
 	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/health"
 	"github.com/gonitro/nitro/app/router"
 )
 
@@ -35,6 +36,9 @@ func LookupRoute(ctx context.Context, req Request, opts CallOptions) ([]string,
 		return nil, errors.InternalServerError("nitro", "error getting next %s node: %s", req.App(), err.Error())
 	}
 
+	// skip unhealthy routes
+	routes = healthy(routes)
+
 	// sort by lowest metric first
 	sort.Slice(routes, func(i, j int) bool {
 		return routes[i].Metric < routes[j].Metric
@@ -48,3 +52,22 @@ func LookupRoute(ctx context.Context, req Request, opts CallOptions) ([]string,
 
 	return addrs, nil
 }
+
+// healthy filters out routes advertised as not serving. If
+// none of the routes are healthy they're all returned.
+func healthy(routes []router.Route) []router.Route {
+	var filtered []router.Route
+
+	for _, route := range routes {
+		if route.Metadata[health.MetadataKey] == health.NotServing {
+			continue
+		}
+		filtered = append(filtered, route)
+	}
+
+	if len(filtered) == 0 {
+		return routes
+	}
+
+	return filtered
+}
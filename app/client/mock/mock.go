@@ -0,0 +1,502 @@
+// Package mock is a client which serves calls from expectations set by tests
+//
+//	c := mock.NewClient()
+//	c.Expect("greeter", "Greeter.Hello").With(&pb.Request{Name: "John"}).Return(&pb.Response{Msg: "Hello John"})
+//
+//	// run the code under test with c
+//
+//	if err := c.Verify(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// Handlers are tested without a server using rpc.ServeHandler.
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/codec"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
+)
+
+// Expect is the expectation of a call to an endpoint
+type Expect struct {
+	App      string
+	Endpoint string
+
+	request  interface{}
+	response interface{}
+	stream   []interface{}
+	err      error
+	delay    time.Duration
+	times    int
+
+	// calls matched, protected by the client lock
+	calls []*Call
+}
+
+// With only matches calls with a request equal to the given one
+func (e *Expect) With(req interface{}) *Expect {
+	e.request = req
+	return e
+}
+
+// Return sets the response of the call. It's copied into the response of the caller.
+func (e *Expect) Return(rsp interface{}) *Expect {
+	e.response = rsp
+	return e
+}
+
+// Stream sets the responses received in order on a stream
+func (e *Expect) Stream(rsps ...interface{}) *Expect {
+	e.stream = rsps
+	return e
+}
+
+// Error sets the error returned by the call. A stream returns it after its responses.
+func (e *Expect) Error(err error) *Expect {
+	e.err = err
+	return e
+}
+
+// Delay the call by the given duration or until the context is done
+func (e *Expect) Delay(d time.Duration) *Expect {
+	e.delay = d
+	return e
+}
+
+// Times limits the number of calls matched. Verify fails unless exactly n calls were made.
+func (e *Expect) Times(n int) *Expect {
+	e.times = n
+	return e
+}
+
+// matches returns whether a call can be served by the expectation. Called under lock.
+func (e *Expect) matches(req client.Request) bool {
+	if e.App != req.App() || e.Endpoint != req.Endpoint() {
+		return false
+	}
+	if e.times > 0 && len(e.calls) >= e.times {
+		return false
+	}
+	if e.request != nil && !equal(e.request, req.Body()) {
+		return false
+	}
+	return true
+}
+
+// Call is a call made to the client
+type Call struct {
+	App      string
+	Endpoint string
+	// Request is the body of the request
+	Request interface{}
+	// Metadata of the context
+	Metadata metadata.Metadata
+	// Sent are the messages sent on a stream
+	Sent []interface{}
+}
+
+// Client is a client.Client which serves calls from expectations
+type Client struct {
+	opts client.Options
+
+	sync.Mutex
+	expects   []*Expect
+	calls     []*Call
+	published []client.Message
+}
+
+// NewClient returns a mock client
+func NewClient(opts ...client.Option) *Client {
+	return &Client{
+		opts: client.NewOptions(opts...),
+	}
+}
+
+// Expect a call to the endpoint. Expectations are matched in the order they're added.
+func (c *Client) Expect(app, endpoint string) *Expect {
+	c.Lock()
+	defer c.Unlock()
+
+	e := &Expect{App: app, Endpoint: endpoint}
+	c.expects = append(c.expects, e)
+	return e
+}
+
+// Calls returns the calls made to the endpoint. An empty app and endpoint returns every call.
+func (c *Client) Calls(app, endpoint string) []*Call {
+	c.Lock()
+	defer c.Unlock()
+
+	var calls []*Call
+
+	for _, call := range c.calls {
+		if len(app) > 0 && call.App != app {
+			continue
+		}
+		if len(endpoint) > 0 && call.Endpoint != endpoint {
+			continue
+		}
+		calls = append(calls, call)
+	}
+
+	return calls
+}
+
+// Published returns the messages published
+func (c *Client) Published() []client.Message {
+	c.Lock()
+	defer c.Unlock()
+
+	return append([]client.Message{}, c.published...)
+}
+
+// Verify returns an error if an expectation wasn't called or was
+// called a different number of times than expected
+func (c *Client) Verify() error {
+	c.Lock()
+	defer c.Unlock()
+
+	var errs []string
+
+	for _, e := range c.expects {
+		switch {
+		case e.times > 0 && len(e.calls) != e.times:
+			errs = append(errs, fmt.Sprintf("%s %s called %d times expected %d", e.App, e.Endpoint, len(e.calls), e.times))
+		case len(e.calls) == 0:
+			errs = append(errs, fmt.Sprintf("%s %s not called", e.App, e.Endpoint))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("mock: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// call records the call and returns the expectation which serves it
+func (c *Client) call(ctx context.Context, req client.Request) (*Expect, *Call, error) {
+	md, _ := metadata.FromContext(ctx)
+
+	call := &Call{
+		App:      req.App(),
+		Endpoint: req.Endpoint(),
+		Request:  req.Body(),
+		Metadata: md,
+	}
+
+	c.Lock()
+	c.calls = append(c.calls, call)
+
+	var expect *Expect
+	for _, e := range c.expects {
+		if e.matches(req) {
+			expect = e
+			e.calls = append(e.calls, call)
+			break
+		}
+	}
+	c.Unlock()
+
+	if expect == nil {
+		return nil, call, errors.NotFound("nitro.mock", "unexpected call to %s %s", req.App(), req.Endpoint())
+	}
+
+	if expect.delay > 0 {
+		t := time.NewTimer(expect.delay)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, call, errors.Timeout("nitro.mock", "%v", ctx.Err())
+		}
+	}
+
+	return expect, call, nil
+}
+
+func (c *Client) Init(opts ...client.Option) error {
+	for _, o := range opts {
+		o(&c.opts)
+	}
+	return nil
+}
+
+func (c *Client) Options() client.Options {
+	return c.opts
+}
+
+func (c *Client) NewMessage(event string, msg interface{}, opts ...client.MessageOption) client.Message {
+	options := client.MessageOptions{
+		ContentType: c.opts.ContentType,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if len(options.ContentType) == 0 {
+		options.ContentType = "application/json"
+	}
+
+	return &message{
+		event:       event,
+		payload:     msg,
+		contentType: options.ContentType,
+	}
+}
+
+func (c *Client) NewRequest(app, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	options := client.RequestOptions{
+		ContentType: c.opts.ContentType,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if len(options.ContentType) == 0 {
+		options.ContentType = "application/json"
+	}
+
+	return &request{
+		app:         app,
+		endpoint:    endpoint,
+		body:        req,
+		contentType: options.ContentType,
+		stream:      options.Stream,
+	}
+}
+
+func (c *Client) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	e, _, err := c.call(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if e.err != nil {
+		return e.err
+	}
+
+	if e.response != nil {
+		return copyValue(e.response, rsp)
+	}
+
+	return nil
+}
+
+func (c *Client) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	e, call, err := c.call(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.err != nil && len(e.stream) == 0 {
+		return nil, e.err
+	}
+
+	return &stream{
+		ctx:     ctx,
+		c:       c,
+		call:    call,
+		request: req,
+		recv:    e.stream,
+		err:     e.err,
+	}, nil
+}
+
+func (c *Client) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	c.Lock()
+	c.published = append(c.published, msg)
+	c.Unlock()
+	return nil
+}
+
+func (c *Client) String() string {
+	return "mock"
+}
+
+type stream struct {
+	ctx     context.Context
+	c       *Client
+	call    *Call
+	request client.Request
+
+	sync.Mutex
+	recv   []interface{}
+	err    error
+	closed bool
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream) Request() client.Request {
+	return s.request
+}
+
+func (s *stream) Response() client.Response {
+	return &response{}
+}
+
+func (s *stream) Send(msg interface{}) error {
+	s.Lock()
+	closed := s.closed
+	s.Unlock()
+
+	if closed {
+		return io.EOF
+	}
+
+	s.c.Lock()
+	s.call.Sent = append(s.call.Sent, msg)
+	s.c.Unlock()
+
+	return nil
+}
+
+func (s *stream) Recv(msg interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return io.EOF
+	}
+
+	if len(s.recv) == 0 {
+		if s.err != nil {
+			return s.err
+		}
+		return io.EOF
+	}
+
+	rsp := s.recv[0]
+	s.recv = s.recv[1:]
+
+	return copyValue(rsp, msg)
+}
+
+func (s *stream) Error() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.err
+}
+
+func (s *stream) Close() error {
+	s.Lock()
+	s.closed = true
+	s.Unlock()
+	return nil
+}
+
+type request struct {
+	app         string
+	endpoint    string
+	body        interface{}
+	contentType string
+	stream      bool
+}
+
+func (r *request) App() string {
+	return r.app
+}
+
+func (r *request) Method() string {
+	return r.endpoint
+}
+
+func (r *request) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *request) ContentType() string {
+	return r.contentType
+}
+
+func (r *request) Body() interface{} {
+	return r.body
+}
+
+func (r *request) Codec() codec.Writer {
+	return nil
+}
+
+func (r *request) Stream() bool {
+	return r.stream
+}
+
+type response struct{}
+
+func (r *response) Codec() codec.Reader {
+	return nil
+}
+
+func (r *response) Header() map[string]string {
+	return map[string]string{}
+}
+
+func (r *response) Read() ([]byte, error) {
+	return nil, nil
+}
+
+type message struct {
+	event       string
+	payload     interface{}
+	contentType string
+}
+
+func (m *message) Event() string {
+	return m.event
+}
+
+func (m *message) Payload() interface{} {
+	return m.payload
+}
+
+func (m *message) ContentType() string {
+	return m.contentType
+}
+
+// copyValue copies the value into the destination as it would be
+// encoded and decoded over the network
+func copyValue(src, dst interface{}) error {
+	if dst == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, dst)
+}
+
+// equal compares values as they'd be encoded over the network so
+// pointers and values of the same request are equal
+func equal(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return string(ab) == string(bb)
+}
@@ -0,0 +1,127 @@
+package mock
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
+)
+
+type testRequest struct {
+	Name string
+}
+
+type testResponse struct {
+	Greeting string
+}
+
+func TestCall(t *testing.T) {
+	c := NewClient()
+	c.Expect("greeter", "Greeter.Hello").With(testRequest{Name: "John"}).Return(&testResponse{Greeting: "Hello John"}).Times(2)
+	c.Expect("greeter", "Greeter.Hello").Error(errors.NotFound("greeter", "not found"))
+	c.Expect("greeter", "Greeter.Slow").Delay(time.Minute)
+
+	ctx := metadata.Set(context.TODO(), "Foo", "Bar")
+
+	for i := 0; i < 2; i++ {
+		rsp := new(testResponse)
+		if err := c.Call(ctx, c.NewRequest("greeter", "Greeter.Hello", &testRequest{Name: "John"}), rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Greeting != "Hello John" {
+			t.Fatalf("Unexpected response %+v", rsp)
+		}
+	}
+
+	// the first expectation is used up
+	err := c.Call(ctx, c.NewRequest("greeter", "Greeter.Hello", &testRequest{Name: "John"}), new(testResponse))
+	if errors.FromError(err).Code != 404 {
+		t.Fatalf("Expected not found got %v", err)
+	}
+
+	// unexpected calls fail
+	if err := c.Call(ctx, c.NewRequest("greeter", "Greeter.Missing", nil), nil); err == nil {
+		t.Fatal("Expected unexpected call to fail")
+	}
+
+	// delays respect the context
+	tctx, cancel := context.WithTimeout(ctx, time.Millisecond*10)
+	defer cancel()
+	if err := c.Call(tctx, c.NewRequest("greeter", "Greeter.Slow", nil), nil); errors.FromError(err).Code != 408 {
+		t.Fatalf("Expected timeout got %v", err)
+	}
+
+	calls := c.Calls("greeter", "Greeter.Hello")
+	if len(calls) != 3 || calls[0].Request.(*testRequest).Name != "John" || calls[0].Metadata["Foo"] != "Bar" {
+		t.Fatalf("Unexpected calls %+v", calls)
+	}
+
+	if err := c.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Expect("greeter", "Greeter.Never")
+	if err := c.Verify(); err == nil {
+		t.Fatal("Expected verify to fail")
+	}
+}
+
+func TestStream(t *testing.T) {
+	c := NewClient()
+	c.Expect("greeter", "Greeter.Stream").Stream(
+		&testResponse{Greeting: "one"},
+		&testResponse{Greeting: "two"},
+	)
+
+	s, err := c.Stream(context.TODO(), c.NewRequest("greeter", "Greeter.Stream", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(&testRequest{Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, greeting := range []string{"one", "two"} {
+		rsp := new(testResponse)
+		if err := s.Recv(rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Greeting != greeting {
+			t.Fatalf("Expected %s got %s", greeting, rsp.Greeting)
+		}
+	}
+
+	if err := s.Recv(new(testResponse)); err != io.EOF {
+		t.Fatalf("Expected EOF got %v", err)
+	}
+
+	if sent := c.Calls("", "")[0].Sent; len(sent) != 1 {
+		t.Fatalf("Expected 1 message sent got %d", len(sent))
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	failed := errors.InternalServerError("greeter", "failed")
+
+	c := NewClient()
+	c.Expect("greeter", "Greeter.Stream").Stream(&testResponse{Greeting: "one"}).Error(failed)
+
+	s, err := c.Stream(context.TODO(), c.NewRequest("greeter", "Greeter.Stream", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Recv(new(testResponse)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Recv(new(testResponse)); err != failed {
+		t.Fatalf("Expected %v got %v", failed, err)
+	}
+	if err := s.Error(); err != failed {
+		t.Fatalf("Expected stream error %v got %v", failed, err)
+	}
+}
@@ -19,6 +19,8 @@ type Options struct {
 	ContentType string
 	// Proxy address to send requests via
 	Proxy string
+	// Local calls servers in the same process directly
+	Local bool
 
 	// Plugged interfaces
 	Broker    event.Broker
@@ -33,6 +35,9 @@ type Options struct {
 	// Connection Pool
 	PoolSize int
 	PoolTTL  time.Duration
+	// PoolConns is the number of connections per address calls
+	// are multiplexed over. Zero uses a connection per call.
+	PoolConns int
 
 	// Middleware for client
 	Wrappers []Wrapper
@@ -70,9 +75,18 @@ type CallOptions struct {
 	AuthToken bool
 	// Network to lookup the route within
 	Network string
+	// HedgeDelay is the time to wait for a response before sending a hedged
+	// request if the latency of the endpoint isn't known
+	HedgeDelay time.Duration
+	// HedgeMax is the max number of hedged requests sent per call
+	HedgeMax int
+	// RetryAfter is set to the last time the server asked to wait before retrying
+	RetryAfter *time.Duration
 
 	// Middleware for low level call func
 	CallWrappers []CallWrapper
+	// StreamWrappers wrap each stream created
+	StreamWrappers []StreamWrapper
 
 	// Other options for implementations of the interface
 	// can be stored in a context
@@ -104,6 +118,7 @@ func NewOptions(options ...Option) Options {
 	opts := Options{
 		Context:     context.Background(),
 		ContentType: "application/json",
+		Local:       true,
 		Codecs:      make(map[string]codec.NewCodec),
 		CallOptions: CallOptions{
 			Backoff:        DefaultBackoff,
@@ -115,6 +130,7 @@ func NewOptions(options ...Option) Options {
 		Lookup:    LookupRoute,
 		PoolSize:  DefaultPoolSize,
 		PoolTTL:   DefaultPoolTTL,
+		PoolConns: DefaultPoolConns,
 		Broker:    mevent.NewBroker(),
 		Router:    regRouter.NewRouter(),
 		Selector:  new(router.RoundRobin),
@@ -170,6 +186,22 @@ func PoolTTL(d time.Duration) Option {
 	}
 }
 
+// PoolConns sets the number of connections per address calls are
+// multiplexed over. Set to zero to use a connection per call.
+func PoolConns(n int) Option {
+	return func(o *Options) {
+		o.PoolConns = n
+	}
+}
+
+// Local sets whether requests to a server running in the same process
+// on the same transport are served directly rather than over the network
+func Local(b bool) Option {
+	return func(o *Options) {
+		o.Local = b
+	}
+}
+
 // Transport to use for communication e.g http, rabbitmq, etc
 func Transport(t network.Transport) Option {
 	return func(o *Options) {
@@ -212,6 +244,13 @@ func WrapCall(cw ...CallWrapper) Option {
 	}
 }
 
+// WrapStream adds to the list of wrappers applied to each stream
+func WrapStream(sw ...StreamWrapper) Option {
+	return func(o *Options) {
+		o.CallOptions.StreamWrappers = append(o.CallOptions.StreamWrappers, sw...)
+	}
+}
+
 // Backoff is used to set the backoff function used
 // when retrying Calls
 func Backoff(fn BackoffFunc) Option {
@@ -294,6 +333,13 @@ func WithCallWrapper(cw ...CallWrapper) CallOption {
 	}
 }
 
+// WithStreamWrapper is a CallOption which adds to the existing stream wrappers
+func WithStreamWrapper(sw ...StreamWrapper) CallOption {
+	return func(o *CallOptions) {
+		o.StreamWrappers = append(o.StreamWrappers, sw...)
+	}
+}
+
 // WithBackoff is a CallOption which overrides that which
 // set in Options.CallOptions
 func WithBackoff(fn BackoffFunc) CallOption {
@@ -356,6 +402,27 @@ func WithNetwork(n string) CallOption {
 	}
 }
 
+// WithHedging sends up to max additional requests to other nodes, each after
+// the HedgePercentile of the endpoint latency tracked by the router without a
+// response, and returns the first response. The delay is used until enough
+// calls were made or if the router doesn't track latency. Only use for
+// idempotent calls.
+func WithHedging(delay time.Duration, max int) CallOption {
+	return func(o *CallOptions) {
+		o.HedgeDelay = delay
+		o.HedgeMax = max
+	}
+}
+
+// WithRetryAfter sets d to the time the server last asked the call to wait
+// before retrying e.g when the request was shed. It's left unchanged if the
+// server didn't ask to wait.
+func WithRetryAfter(d *time.Duration) CallOption {
+	return func(o *CallOptions) {
+		o.RetryAfter = d
+	}
+}
+
 // WithRouter sets the router to use for this call
 func WithRouter(r router.Router) CallOption {
 	return func(o *CallOptions) {
@@ -0,0 +1,272 @@
+// Package record records RPC traffic as JSON lines and replays it
+//
+//	f, _ := os.Create("traffic.json")
+//	r := record.NewRecorder(f)
+//
+//	c := rpc.NewClient(client.WrapCall(r.Call))
+//	s := rpc.NewServer(server.WrapHandler(r.Handler))
+//
+// The recording is replayed by a client which serves the recorded responses
+//
+//	f, _ := os.Open("traffic.json")
+//	c, err := record.Replay(f)
+//
+// The values of credential headers such as Authorization are redacted so
+// they aren't saved in the recording. See the Redact and Allow options.
+package record
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/client/mock"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/server"
+)
+
+// Record is a single recorded call
+type Record struct {
+	Time time.Time `json:"time"`
+	// Side is client or server
+	Side     string            `json:"side"`
+	App      string            `json:"app"`
+	Endpoint string            `json:"endpoint"`
+	Address  string            `json:"address,omitempty"`
+	Header   map[string]string `json:"header,omitempty"`
+	Request  json.RawMessage   `json:"request"`
+	Response json.RawMessage   `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Duration time.Duration     `json:"duration"`
+}
+
+var (
+	// DefaultRedact are the headers whose values are redacted by default
+	DefaultRedact = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	// Redacted replaces the value of a redacted header
+	Redacted = "REDACTED"
+)
+
+// Options of a recorder
+type Options struct {
+	// Redact are the headers whose values are redacted
+	Redact []string
+	// Allow are the only headers whose values are recorded if set
+	Allow []string
+}
+
+// Option sets an option of a recorder
+type Option func(*Options)
+
+// Redact sets the headers whose values are redacted, replacing the DefaultRedact
+func Redact(headers ...string) Option {
+	return func(o *Options) {
+		o.Redact = headers
+	}
+}
+
+// Allow sets the only headers whose values are recorded. The values of
+// all other headers are redacted. Redacted headers are never recorded.
+func Allow(headers ...string) Option {
+	return func(o *Options) {
+		o.Allow = headers
+	}
+}
+
+// Recorder writes the calls it wraps to a writer as JSON lines
+type Recorder struct {
+	sync.Mutex
+	enc *json.Encoder
+	// lower case header names
+	redact map[string]bool
+	allow  map[string]bool
+}
+
+// NewRecorder returns a recorder which writes to w
+func NewRecorder(w io.Writer, opts ...Option) *Recorder {
+	options := Options{
+		Redact: DefaultRedact,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	r := &Recorder{
+		enc:    json.NewEncoder(w),
+		redact: make(map[string]bool, len(options.Redact)),
+	}
+	for _, h := range options.Redact {
+		r.redact[strings.ToLower(h)] = true
+	}
+	if len(options.Allow) > 0 {
+		r.allow = make(map[string]bool, len(options.Allow))
+		for _, h := range options.Allow {
+			r.allow[strings.ToLower(h)] = true
+		}
+	}
+	return r
+}
+
+// header returns a copy of the header with the values of credentials redacted
+func (r *Recorder) header(hdr map[string]string) map[string]string {
+	if len(hdr) == 0 {
+		return nil
+	}
+
+	md := make(map[string]string, len(hdr))
+	for k, v := range hdr {
+		key := strings.ToLower(k)
+		if r.redact[key] || (r.allow != nil && !r.allow[key]) {
+			v = Redacted
+		}
+		md[k] = v
+	}
+	return md
+}
+
+// write the record. Calls which can't be encoded aren't recorded.
+func (r *Recorder) write(rec *Record, req, rsp interface{}, err error) {
+	b, merr := json.Marshal(req)
+	if merr != nil {
+		return
+	}
+	rec.Request = b
+
+	if err != nil {
+		rec.Error = err.Error()
+	} else if rsp != nil {
+		if b, merr = json.Marshal(rsp); merr != nil {
+			return
+		}
+		rec.Response = b
+	}
+
+	r.Lock()
+	r.enc.Encode(rec)
+	r.Unlock()
+}
+
+// Call is a client.CallWrapper which records the calls made
+func (r *Recorder) Call(fn client.CallFunc) client.CallFunc {
+	return func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+		start := time.Now()
+		err := fn(ctx, addr, req, rsp, opts)
+
+		md, _ := metadata.FromContext(ctx)
+
+		r.write(&Record{
+			Time:     start,
+			Side:     "client",
+			App:      req.App(),
+			Endpoint: req.Endpoint(),
+			Address:  addr,
+			Header:   r.header(md),
+			Duration: time.Since(start),
+		}, req.Body(), rsp, err)
+
+		return err
+	}
+}
+
+// Handler is a server.HandlerWrapper which records the requests served. Streams aren't recorded.
+func (r *Recorder) Handler(fn server.HandlerFunc) server.HandlerFunc {
+	return func(ctx context.Context, req server.Request, rsp interface{}) error {
+		if req.Stream() {
+			return fn(ctx, req, rsp)
+		}
+
+		start := time.Now()
+		err := fn(ctx, req, rsp)
+
+		r.write(&Record{
+			Time:     start,
+			Side:     "server",
+			App:      req.App(),
+			Endpoint: req.Endpoint(),
+			Header:   r.header(req.Header()),
+			Duration: time.Since(start),
+		}, req.Body(), rsp, err)
+
+		return err
+	}
+}
+
+// Read the records written by a recorder
+func Read(r io.Reader) ([]*Record, error) {
+	var records []*Record
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	for {
+		rec := new(Record)
+		if err := dec.Decode(rec); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// Replay returns a client which serves the recorded calls. Calls are matched on the
+// app, endpoint and request. A request recorded many times is served its responses
+// in the order they were recorded and the last response once they're used up.
+// A request recorded by both the client and the server is served the client records.
+func Replay(r io.Reader, opts ...client.Option) (*mock.Client, error) {
+	all, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// requests recorded by the client
+	called := make(map[string]bool)
+	for _, rec := range all {
+		if rec.Side == "client" {
+			called[key(rec)] = true
+		}
+	}
+
+	// skip the server records of a call already recorded by the client
+	var records []*Record
+	for _, rec := range all {
+		if rec.Side == "server" && called[key(rec)] {
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	c := mock.NewClient(opts...)
+
+	// the index of the last record of each request
+	last := make(map[string]int)
+	for i, rec := range records {
+		last[key(rec)] = i
+	}
+
+	for i, rec := range records {
+		e := c.Expect(rec.App, rec.Endpoint).With(rec.Request)
+
+		if len(rec.Error) > 0 {
+			e.Error(errors.Parse(rec.Error))
+		} else if len(rec.Response) > 0 {
+			e.Return(rec.Response)
+		}
+
+		if last[key(rec)] != i {
+			e.Times(1)
+		}
+	}
+
+	return c, nil
+}
+
+// key of the request which was recorded
+func key(rec *Record) string {
+	return rec.App + "/" + rec.Endpoint + "/" + string(rec.Request)
+}
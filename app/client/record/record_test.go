@@ -0,0 +1,243 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/server"
+)
+
+type testRequest struct {
+	Name string
+}
+
+type testResponse struct {
+	Greeting string
+	Count    int
+}
+
+type clientRequest struct {
+	client.Request
+	endpoint string
+	body     interface{}
+}
+
+func (r *clientRequest) App() string {
+	return "greeter"
+}
+
+func (r *clientRequest) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *clientRequest) Body() interface{} {
+	return r.body
+}
+
+type serverRequest struct {
+	server.Request
+	header map[string]string
+	body   interface{}
+}
+
+func (r *serverRequest) App() string {
+	return "greeter"
+}
+
+func (r *serverRequest) Endpoint() string {
+	return "Greeter.Hello"
+}
+
+func (r *serverRequest) Header() map[string]string {
+	if r.header != nil {
+		return r.header
+	}
+	return map[string]string{"Foo": "Bar"}
+}
+
+func (r *serverRequest) Body() interface{} {
+	return r.body
+}
+
+func (r *serverRequest) Stream() bool {
+	return false
+}
+
+func TestRecordReplay(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := NewRecorder(buf)
+
+	var count int
+
+	call := r.Call(func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+		if req.Endpoint() == "Greeter.Fail" {
+			return errors.NotFound("greeter", "not found")
+		}
+		count++
+		rsp.(*testResponse).Greeting = "Hello " + req.Body().(*testRequest).Name
+		rsp.(*testResponse).Count = count
+		return nil
+	})
+
+	c := client.NewOptions()
+	ctx := metadata.Set(context.TODO(), "Foo", "Bar")
+
+	for _, name := range []string{"John", "John", "Jane"} {
+		req := &clientRequest{endpoint: "Greeter.Hello", body: &testRequest{Name: name}}
+		if err := call(ctx, "10.0.0.1:8080", req, new(testResponse), c.CallOptions); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := call(ctx, "10.0.0.1:8080", &clientRequest{endpoint: "Greeter.Fail"}, new(testResponse), c.CallOptions); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	handler := r.Handler(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		rsp.(*testResponse).Greeting = "Hi"
+		return nil
+	})
+	if err := handler(ctx, &serverRequest{body: &testRequest{Name: "Server"}}, new(testResponse)); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || records[0].Header["Foo"] != "Bar" || records[0].Address != "10.0.0.1:8080" || records[4].Side != "server" {
+		t.Fatalf("Unexpected records %+v", records)
+	}
+
+	rc, err := Replay(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// repeated requests are served in order then the last response
+	for _, n := range []int{1, 2, 2} {
+		rsp := new(testResponse)
+		if err := rc.Call(ctx, rc.NewRequest("greeter", "Greeter.Hello", &testRequest{Name: "John"}), rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Greeting != "Hello John" || rsp.Count != n {
+			t.Fatalf("Expected response %d got %+v", n, rsp)
+		}
+	}
+
+	rsp := new(testResponse)
+	if err := rc.Call(ctx, rc.NewRequest("greeter", "Greeter.Hello", &testRequest{Name: "Jane"}), rsp); err != nil || rsp.Count != 3 {
+		t.Fatalf("Unexpected response %+v %v", rsp, err)
+	}
+
+	err = rc.Call(ctx, rc.NewRequest("greeter", "Greeter.Fail", nil), new(testResponse))
+	if errors.FromError(err).Code != 404 {
+		t.Fatalf("Expected recorded error got %v", err)
+	}
+
+	// unrecorded requests fail
+	if err := rc.Call(ctx, rc.NewRequest("greeter", "Greeter.Hello", &testRequest{Name: "Bob"}), rsp); err == nil {
+		t.Fatal("Expected unrecorded request to fail")
+	}
+}
+
+func TestReplayBothSides(t *testing.T) {
+	buf := new(bytes.Buffer)
+	r := NewRecorder(buf)
+
+	// the call is recorded by the client and the server it's served by
+	handler := r.Handler(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		rsp.(*testResponse).Greeting = "Hello " + req.Body().(*testRequest).Name
+		return nil
+	})
+	call := r.Call(func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+		return handler(ctx, &serverRequest{body: req.Body()}, rsp)
+	})
+
+	req := &clientRequest{endpoint: "Greeter.Hello", body: &testRequest{Name: "John"}}
+	if err := call(context.TODO(), "10.0.0.1:8080", req, new(testResponse), client.NewOptions().CallOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := Replay(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsp := new(testResponse)
+	if err := rc.Call(context.TODO(), rc.NewRequest("greeter", "Greeter.Hello", &testRequest{Name: "John"}), rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Greeting != "Hello John" {
+		t.Fatalf("Expected Hello John got %+v", rsp)
+	}
+
+	// the call is expected once
+	if err := rc.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordRedact(t *testing.T) {
+	const token = "Bearer secret-token"
+
+	testData := []struct {
+		opts   []Option
+		header map[string]string
+	}{
+		// credentials are redacted by default
+		{nil, map[string]string{"Authorization": Redacted, "Foo": "Bar"}},
+		// only the allowed headers are recorded
+		{[]Option{Allow("Authorization")}, map[string]string{"Authorization": Redacted, "Foo": Redacted}},
+		// the headers to redact can be replaced
+		{[]Option{Redact("Foo")}, map[string]string{"Authorization": token, "Foo": Redacted}},
+	}
+
+	for _, d := range testData {
+		buf := new(bytes.Buffer)
+		r := NewRecorder(buf, d.opts...)
+
+		handler := r.Handler(func(ctx context.Context, req server.Request, rsp interface{}) error {
+			return nil
+		})
+		call := r.Call(func(ctx context.Context, addr string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			md, _ := metadata.FromContext(ctx)
+			return handler(ctx, &serverRequest{header: md, body: req.Body()}, rsp)
+		})
+
+		ctx := metadata.NewContext(context.TODO(), map[string]string{"Authorization": token, "Foo": "Bar"})
+		req := &clientRequest{endpoint: "Greeter.Hello", body: &testRequest{Name: "John"}}
+		if err := call(ctx, "10.0.0.1:8080", req, new(testResponse), client.NewOptions().CallOptions); err != nil {
+			t.Fatal(err)
+		}
+
+		if d.header["Authorization"] == Redacted && strings.Contains(buf.String(), "secret-token") {
+			t.Fatalf("Expected token to be redacted got %s", buf.String())
+		}
+
+		records, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("Expected 2 records got %d", len(records))
+		}
+		for _, rec := range records {
+			for k, v := range d.header {
+				if rec.Header[k] != v {
+					t.Fatalf("Expected %s header %q on the %s got %q", k, v, rec.Side, rec.Header[k])
+				}
+			}
+		}
+
+		// the caller's metadata isn't changed
+		if md, _ := metadata.Get(ctx, "Authorization"); md != token {
+			t.Fatalf("Expected metadata %q got %q", token, md)
+		}
+	}
+}
@@ -15,7 +15,7 @@ func RetryAlways(ctx context.Context, req Request, retryCount int, err error) (b
 	return true, nil
 }
 
-// RetryOnError retries a request on a 500 or timeout error
+// RetryOnError retries a request on a 500, 503 or timeout error
 func RetryOnError(ctx context.Context, req Request, retryCount int, err error) (bool, error) {
 	if err == nil {
 		return false, nil
@@ -27,8 +27,8 @@ func RetryOnError(ctx context.Context, req Request, retryCount int, err error) (
 	}
 
 	switch e.Code {
-	// retry on timeout or internal server error
-	case http.StatusRequestTimeout, http.StatusInternalServerError:
+	// retry on timeout, internal server error or unavailable
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusServiceUnavailable:
 		return true, nil
 	default:
 		return false, nil
@@ -4,6 +4,9 @@ package rpc
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,6 +17,8 @@ import (
 	"github.com/gonitro/nitro/app/event"
 	"github.com/gonitro/nitro/app/metadata"
 	"github.com/gonitro/nitro/app/network"
+	"github.com/gonitro/nitro/app/router"
+	"github.com/gonitro/nitro/app/server"
 	"github.com/gonitro/nitro/util/buf"
 	"github.com/gonitro/nitro/util/pool"
 	"github.com/gonitro/nitro/util/uuid"
@@ -30,15 +35,9 @@ type rpcClient struct {
 func NewClient(opt ...client.Option) client.Client {
 	opts := client.NewOptions(opt...)
 
-	p := pool.NewPool(
-		pool.Size(opts.PoolSize),
-		pool.TTL(opts.PoolTTL),
-		pool.Transport(opts.Transport),
-	)
-
 	rc := &rpcClient{
 		opts: opts,
-		pool: p,
+		pool: newPool(opts),
 		seq:  0,
 	}
 	rc.once.Store(false)
@@ -53,6 +52,20 @@ func NewClient(opt ...client.Option) client.Client {
 	return c
 }
 
+// newPool returns a pool which multiplexes calls over PoolConns
+// connections per address or a pool with a connection per call
+func newPool(opts client.Options) pool.Pool {
+	if opts.PoolConns > 0 {
+		return newMuxPool(opts.PoolConns, opts.PoolTTL, opts.Transport)
+	}
+
+	return pool.NewPool(
+		pool.Size(opts.PoolSize),
+		pool.TTL(opts.PoolTTL),
+		pool.Transport(opts.Transport),
+	)
+}
+
 func (r *rpcClient) newCodec(contentType string) (codec.NewCodec, error) {
 	if c, ok := r.opts.Codecs[contentType]; ok {
 		return c, nil
@@ -87,6 +100,11 @@ func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, r
 	// set the accept header
 	msg.Header["Accept"] = req.ContentType()
 
+	// call the handler directly if the server is in this process
+	if err := r.local(ctx, addr, msg.Header, req, resp); err != server.ErrNotLocal {
+		return err
+	}
+
 	cf, err := r.newCodec(req.ContentType())
 	if err != nil {
 		return errors.InternalServerError("nitro", err.Error())
@@ -156,9 +174,14 @@ func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, r
 
 	select {
 	case err := <-ch:
+		if err != nil {
+			setRetryAfter(ctx, rsp.Header())
+		}
 		return err
 	case <-ctx.Done():
 		grr = errors.Timeout("nitro", fmt.Sprintf("%v", ctx.Err()))
+		// stop the server processing the request
+		stream.cancel()
 	}
 
 	// set the stream error
@@ -173,6 +196,41 @@ func (r *rpcClient) call(ctx context.Context, addr string, req client.Request, r
 	return nil
 }
 
+// local serves the request via a server running in this process. It
+// returns server.ErrNotLocal if the request must go over the network.
+func (r *rpcClient) local(ctx context.Context, addr string, header map[string]string, req client.Request, resp interface{}) error {
+	if !r.opts.Local {
+		return server.ErrNotLocal
+	}
+
+	// only servers listening on our transport can be reached
+	srv, ok := server.LookupLocal(r.opts.Transport, addr)
+	if !ok {
+		return server.ErrNotLocal
+	}
+
+	hdr := make(map[string]string, len(header)+3)
+	for k, v := range header {
+		hdr[k] = v
+	}
+	hdr["App"] = req.App()
+	hdr["Method"] = req.Method()
+	hdr["Endpoint"] = req.Endpoint()
+
+	err := srv.ServeLocal(ctx, hdr, req.Body(), resp)
+	switch {
+	case err == server.ErrNotLocal:
+		return err
+	case err != nil:
+		setRetryAfter(ctx, hdr)
+		// the error is received as a string over the network
+		return serverError(err.Error())
+	case ctx.Err() != nil:
+		return errors.Timeout("nitro", fmt.Sprintf("%v", ctx.Err()))
+	}
+	return nil
+}
+
 func (r *rpcClient) stream(ctx context.Context, addr string, req client.Request, opts client.CallOptions) (client.Stream, error) {
 	msg := &network.Message{
 		Header: make(map[string]string),
@@ -271,12 +329,22 @@ func (r *rpcClient) stream(ctx context.Context, addr string, req client.Request,
 		return nil, grr
 	}
 
-	return stream, nil
+	// stop the server processing the stream if the context is done first
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.cancel()
+		case <-stream.closed:
+		}
+	}()
+
+	return wrapStream(stream, opts.StreamWrappers), nil
 }
 
 func (r *rpcClient) Init(opts ...client.Option) error {
 	size := r.opts.PoolSize
 	ttl := r.opts.PoolTTL
+	conns := r.opts.PoolConns
 	tr := r.opts.Transport
 
 	for _, o := range opts {
@@ -284,15 +352,11 @@ func (r *rpcClient) Init(opts ...client.Option) error {
 	}
 
 	// update pool configuration if the options changed
-	if size != r.opts.PoolSize || ttl != r.opts.PoolTTL || tr != r.opts.Transport {
+	if size != r.opts.PoolSize || ttl != r.opts.PoolTTL || conns != r.opts.PoolConns || tr != r.opts.Transport {
 		// close existing pool
 		r.pool.Close()
 		// create new pool
-		r.pool = pool.NewPool(
-			pool.Size(r.opts.PoolSize),
-			pool.TTL(r.opts.PoolTTL),
-			pool.Transport(r.opts.Transport),
-		)
+		r.pool = newPool(r.opts)
 	}
 
 	return nil
@@ -318,8 +382,8 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 	} else {
 		// got a deadline so no need to setup context
 		// but we need to set the timeout we pass along
-		remaining := d.Sub(time.Now())
-		client.WithRequestTimeout(remaining)(&callOpts)
+		// shrink it so the server gives up in time to respond
+		client.WithRequestTimeout(serverTimeout(d.Sub(time.Now())))(&callOpts)
 	}
 
 	// should we noop right here?
@@ -329,6 +393,19 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 	default:
 	}
 
+	// the server may ask for the next attempt to be delayed
+	wait := new(retryAfter)
+	ctx = context.WithValue(ctx, retryAfterKey{}, wait)
+
+	// tell the caller what the server last asked
+	if callOpts.RetryAfter != nil {
+		defer func() {
+			if d := wait.latest(); d > 0 {
+				*callOpts.RetryAfter = d
+			}
+		}()
+	}
+
 	// make copy of call method
 	rcall := r.call
 
@@ -360,32 +437,81 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, selectOptions(callOpts)...)
 	if err != nil {
 		return err
 	}
 
-	// return errors.New("nitro", "request timeout", 408)
-	call := func(i int) error {
-		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, request, i)
-		if err != nil {
-			return errors.InternalServerError("nitro", "backoff error: %v", err.Error())
+	// only hedge if the response can be copied from the winning request
+	hedging := callOpts.HedgeMax > 0 && callOpts.HedgeDelay > 0 && canHedge(response)
+
+	// latencies are tracked by app and endpoint
+	endpoint := request.App() + "/" + request.Endpoint()
+
+	// hedge after a high percentile of the endpoint latency if known
+	hedgeDelay := callOpts.HedgeDelay
+	if hedging {
+		hedgeDelay = delay(callOpts.Router, endpoint, callOpts.HedgeDelay)
+	}
+
+	// the nodes used by the current attempt and its hedged requests
+	var mtx sync.Mutex
+	used := make(map[string]bool)
+
+	// get the next node. Hedged requests skip nodes already in use.
+	nextNode := func(hedge bool) string {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		node := next()
+		for j := 1; hedge && used[node] && j < len(routes); j++ {
+			node = next()
 		}
+		used[node] = true
+		return node
+	}
 
-		// only sleep if greater than 0
-		if t.Seconds() > 0 {
-			time.Sleep(t)
+	// return errors.New("nitro", "request timeout", 408)
+	call := func(ctx context.Context, i, hedge int, rsp interface{}) error {
+		if hedge > 0 {
+			// mark the request so the server knows it's hedged
+			ctx = metadata.Set(ctx, client.HedgeHeader, strconv.Itoa(hedge))
+		} else {
+			// call backoff first. Someone may want an initial start delay
+			t, err := callOpts.Backoff(ctx, request, i)
+			if err != nil {
+				return errors.InternalServerError("nitro", "backoff error: %v", err.Error())
+			}
+
+			// wait at least as long as the server asked
+			if d := wait.take(); d > t {
+				t = d
+			}
+
+			// only sleep if greater than 0
+			if t.Seconds() > 0 {
+				time.Sleep(t)
+			}
 		}
 
 		// get the next node
-		node := next()
+		node := nextNode(hedge > 0)
 
 		// make the call
-		err = rcall(ctx, node, request, response, callOpts)
+		start := time.Now()
+		err := rcall(ctx, node, request, rsp, callOpts)
 
-		// TODO: record call status and provide to router
-		// including time taken to send the call
+		// the call was cancelled because another request won
+		if ctx.Err() == context.Canceled {
+			return err
+		}
+
+		// record the call status with the router
+		d := time.Since(start)
+		record(callOpts.Router, node, d, err)
+		if err == nil {
+			observe(callOpts.Router, endpoint, d)
+		}
 
 		return err
 	}
@@ -398,39 +524,216 @@ func (r *rpcClient) Call(ctx context.Context, request client.Request, response i
 		retries = 0
 	}
 
-	ch := make(chan error, retries+1)
+	type result struct {
+		rsp interface{}
+		err error
+	}
+
+	// cancel any outstanding hedged requests on return
+	hctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan result, retries+callOpts.HedgeMax+1)
+	hedges := 0
 	var gerr error
 
+	send := func(i, hedge int) {
+		rsp := response
+		// each request decodes into its own response when hedging
+		if hedging {
+			rsp = reflect.New(reflect.TypeOf(response).Elem()).Interface()
+		}
+		go func() {
+			ch <- result{rsp, call(hctx, i, hedge, rsp)}
+		}()
+	}
+
 	for i := 0; i <= retries; i++ {
-		go func(i int) {
-			ch <- call(i)
-		}(i)
+		mtx.Lock()
+		used = make(map[string]bool)
+		mtx.Unlock()
+
+		send(i, 0)
+		pending := 1
+
+		// fire a hedged request if there's no response within the delay
+		var timer *time.Timer
+		var hedge <-chan time.Time
+		if hedging && hedges < callOpts.HedgeMax {
+			timer = time.NewTimer(hedgeDelay)
+			hedge = timer.C
+		}
 
-		select {
-		case <-ctx.Done():
-			return errors.Timeout("nitro", fmt.Sprintf("call timeout: %v", ctx.Err()))
-		case err := <-ch:
-			// if the call succeeded lets bail early
-			if err == nil {
-				return nil
+		var err error
+
+		for pending > 0 {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return errors.Timeout("nitro", fmt.Sprintf("call timeout: %v", ctx.Err()))
+			case <-hedge:
+				hedges++
+				pending++
+				send(i, hedges)
+
+				hedge = nil
+				if hedges < callOpts.HedgeMax {
+					timer.Reset(hedgeDelay)
+					hedge = timer.C
+				}
+			case res := <-ch:
+				pending--
+
+				// if the call succeeded lets bail early
+				if res.err == nil {
+					if timer != nil {
+						timer.Stop()
+					}
+					if hedging {
+						reflect.ValueOf(response).Elem().Set(reflect.ValueOf(res.rsp).Elem())
+					}
+					return nil
+				}
+
+				err = res.err
 			}
+		}
 
-			retry, rerr := callOpts.Retry(ctx, request, i, err)
-			if rerr != nil {
-				return rerr
-			}
+		if timer != nil {
+			timer.Stop()
+		}
 
-			if !retry {
-				return err
-			}
+		retry, rerr := callOpts.Retry(ctx, request, i, err)
+		if rerr != nil {
+			return rerr
+		}
 
-			gerr = err
+		if !retry {
+			return err
 		}
+
+		gerr = err
 	}
 
 	return gerr
 }
 
+type retryAfterKey struct{}
+
+// retryAfter is the time the server asked to wait before the next attempt
+type retryAfter struct {
+	sync.Mutex
+	d time.Duration
+	// the last time asked which isn't taken
+	last time.Duration
+}
+
+func (r *retryAfter) set(d time.Duration) {
+	r.Lock()
+	if d > r.d {
+		r.d = d
+	}
+	r.last = d
+	r.Unlock()
+}
+
+func (r *retryAfter) latest() time.Duration {
+	r.Lock()
+	defer r.Unlock()
+	return r.last
+}
+
+func (r *retryAfter) take() time.Duration {
+	r.Lock()
+	defer r.Unlock()
+	d := r.d
+	r.d = 0
+	return d
+}
+
+// setRetryAfter records the retry after header of a response for the call in the context
+func setRetryAfter(ctx context.Context, hdr map[string]string) {
+	wait, ok := ctx.Value(retryAfterKey{}).(*retryAfter)
+	if !ok {
+		return
+	}
+	if d, err := time.ParseDuration(hdr[server.RetryAfterHeader]); err == nil {
+		wait.set(d)
+	}
+}
+
+// canHedge returns true if the response is a pointer which can be copied
+func canHedge(rsp interface{}) bool {
+	v := reflect.ValueOf(rsp)
+	return v.Kind() == reflect.Ptr && !v.IsNil()
+}
+
+// selectOptions returns the options to select a route with, including the
+// metric of each route if the router records it
+func selectOptions(opts client.CallOptions) []router.SelectOption {
+	rec, ok := opts.Router.(router.Recorder)
+	if !ok {
+		return opts.SelectOptions
+	}
+	return append([]router.SelectOption{router.SelectMetric(rec.Metric)}, opts.SelectOptions...)
+}
+
+// record the outcome of a call to a node with the router. Errors
+// caused by the request rather than the node are not counted.
+func record(rtr router.Router, node string, d time.Duration, err error) {
+	rec, ok := rtr.(router.Recorder)
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		switch errors.FromError(err).Code {
+		case 0, 408, 500, 502, 503, 504:
+		default:
+			err = nil
+		}
+	}
+
+	rec.Record(node, d, err)
+}
+
+// observe the latency of a successful call to the endpoint if the router tracks it
+func observe(rtr router.Router, endpoint string, d time.Duration) {
+	if l, ok := rtr.(router.Latencies); ok {
+		l.Observe(endpoint, d)
+	}
+}
+
+// delay returns the time to wait for a response before hedging a call to the
+// endpoint. The fallback is used if the router doesn't know the latency yet.
+func delay(rtr router.Router, endpoint string, fallback time.Duration) time.Duration {
+	l, ok := rtr.(router.Latencies)
+	if !ok {
+		return fallback
+	}
+	if d, ok := l.Percentile(endpoint, client.HedgePercentile); ok && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// serverTimeout returns the timeout passed to the server for the time remaining
+// before the deadline of the call. The margin taken off is the larger of the
+// DeadlineMargin and the DeadlineRatio of the remaining time, but no more than
+// half so a short deadline still leaves the server time to respond.
+func serverTimeout(remaining time.Duration) time.Duration {
+	margin := time.Duration(float64(remaining) * client.DeadlineRatio)
+	if margin < client.DeadlineMargin {
+		margin = client.DeadlineMargin
+	}
+	if margin > remaining/2 {
+		margin = remaining / 2
+	}
+	return remaining - margin
+}
+
 func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...client.CallOption) (client.Stream, error) {
 	// make a copy of call opts
 	callOpts := r.opts.CallOptions
@@ -468,7 +771,7 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 	}
 
 	// balance the list of nodes
-	next, err := callOpts.Selector.Select(routes)
+	next, err := callOpts.Selector.Select(routes, selectOptions(callOpts)...)
 	if err != nil {
 		return nil, err
 	}
@@ -489,9 +792,11 @@ func (r *rpcClient) Stream(ctx context.Context, request client.Request, opts ...
 		node := next()
 
 		// perform the call
+		start := time.Now()
 		stream, err := r.stream(ctx, node, request, callOpts)
 
-		// TODO: record error status with router
+		// record the error status with the router
+		record(callOpts.Router, node, time.Since(start), err)
 
 		return stream, err
 	}
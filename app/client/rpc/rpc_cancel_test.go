@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/server"
+	rpcServer "github.com/gonitro/nitro/app/server/rpc"
+)
+
+// Block waits for the request context to be done
+type Block struct {
+	// time left before the deadline of each request
+	timeouts chan time.Duration
+	done     chan error
+}
+
+func (b *Block) Block(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	d, _ := ctx.Deadline()
+	b.timeouts <- time.Until(d)
+	<-ctx.Done()
+	b.done <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestCallCancel(t *testing.T) {
+	b := &Block{
+		timeouts: make(chan time.Duration, 1),
+		done:     make(chan error, 1),
+	}
+
+	s := rpcServer.NewServer(
+		server.Name("block"),
+		server.Address("127.0.0.1:0"),
+		server.Transport(socket.NewTransport()),
+	)
+	if err := s.Handle(s.NewHandler(b)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	addr := s.Options().Address
+
+	for _, conns := range []int{0, 2} {
+		c := NewClient(
+			client.Router(newTestRouter()),
+			client.Transport(socket.NewTransport()),
+			client.Local(false),
+			client.PoolConns(conns),
+		)
+
+		// cancelling the call cancels the handler
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errs := make(chan error, 1)
+		go func() {
+			req := c.NewRequest("block", "Block.Block", &EchoRequest{})
+			errs <- c.Call(ctx, req, new(EchoResponse), client.WithAddress(addr))
+		}()
+
+		<-b.timeouts
+		cancel()
+
+		if err := <-errs; err == nil {
+			t.Fatal("expected cancelled call to fail")
+		}
+
+		select {
+		case err := <-b.done:
+			if err != context.Canceled {
+				t.Fatalf("expected handler to be cancelled got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("handler not cancelled with %d conns", conns)
+		}
+
+		// the handler timeout is shorter than the caller's by the margin
+		timeout := time.Millisecond * 200
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+
+		req := c.NewRequest("block", "Block.Block", &EchoRequest{})
+		c.Call(ctx, req, new(EchoResponse), client.WithAddress(addr))
+		cancel()
+
+		if d, max := <-b.timeouts, serverTimeout(timeout); d > max {
+			t.Fatalf("expected handler timeout of at most %v got %v", max, d)
+		}
+		<-b.done
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	testData := []struct {
+		remaining time.Duration
+		timeout   time.Duration
+	}{
+		// the ratio of a long deadline
+		{time.Second * 10, time.Second * 9},
+		// the margin of a short deadline
+		{time.Millisecond * 100, time.Millisecond * 80},
+		// no more than half of a very short deadline
+		{time.Millisecond * 10, time.Millisecond * 5},
+	}
+
+	for _, d := range testData {
+		if v := serverTimeout(d.remaining); v != d.timeout {
+			t.Fatalf("Expected timeout %v for %v got %v", d.timeout, d.remaining, v)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/server"
+	rpcServer "github.com/gonitro/nitro/app/server/rpc"
+)
+
+// Failer returns the error named in the request
+type Failer struct{}
+
+func (f *Failer) Fail(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	return errors.NotFound("failer", "%s not found", req.Value)
+}
+
+func TestCallLocalError(t *testing.T) {
+	tr := socket.NewTransport()
+
+	s := rpcServer.NewServer(
+		server.Name("failer"),
+		server.Address("127.0.0.1:0"),
+		server.Transport(tr),
+	)
+	if err := s.Handle(s.NewHandler(new(Failer))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	addr := s.Options().Address
+
+	call := func(local bool) error {
+		c := NewClient(
+			client.Router(newTestRouter()),
+			client.Transport(tr),
+			client.Local(local),
+		)
+		req := c.NewRequest("failer", "Failer.Fail", &EchoRequest{Value: "thing"})
+		return c.Call(context.TODO(), req, new(EchoResponse), client.WithAddress(addr))
+	}
+
+	remote := call(false)
+	local := call(true)
+
+	// the error is the same wherever the server runs
+	if reflect.TypeOf(local) != reflect.TypeOf(remote) || local.Error() != remote.Error() {
+		t.Fatalf("Expected local error %T %v to match remote error %T %v", local, local, remote, remote)
+	}
+	if e := errors.FromError(local); e.Code != 404 || e.Detail != "thing not found" {
+		t.Fatalf("Expected not found error got %v", local)
+	}
+}
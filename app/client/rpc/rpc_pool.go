@@ -0,0 +1,422 @@
+package rpc
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/network"
+	"github.com/gonitro/nitro/util/pool"
+	"github.com/gonitro/nitro/util/uuid"
+)
+
+var (
+	errConnClosed = errors.New("connection closed")
+)
+
+// muxPool multiplexes calls over a small number of long lived connections
+// per address. Each call gets a stream which is registered on the connection
+// by its Id header when the request is sent. A single reader per connection
+// demultiplexes the responses to their stream by the Id header, mirroring
+// the way the server multiplexes by the Stream or Id header.
+type muxPool struct {
+	// connections per address
+	size int
+	ttl  time.Duration
+	tr   network.Transport
+
+	sync.Mutex
+	cond    *sync.Cond
+	conns   map[string][]*muxConn
+	dialing map[string]int
+}
+
+// muxConn is a connection shared by many streams
+type muxConn struct {
+	network.Client
+	pool    *muxPool
+	addr    string
+	created time.Time
+
+	// serialises sends
+	wmtx sync.Mutex
+
+	sync.RWMutex
+	streams map[string]*muxStream
+	// streams given out which are not yet closed
+	active int
+	// conn is no longer given out
+	draining bool
+	err      error
+	closed   chan bool
+}
+
+// muxStream is a single call on a shared connection
+type muxStream struct {
+	conn *muxConn
+	id   string
+
+	sync.Mutex
+	// Id header of the request
+	key    string
+	recv   chan *network.Message
+	closed chan bool
+}
+
+func newMuxPool(size int, ttl time.Duration, tr network.Transport) *muxPool {
+	p := &muxPool{
+		size:    size,
+		ttl:     ttl,
+		tr:      tr,
+		conns:   make(map[string][]*muxConn),
+		dialing: make(map[string]int),
+	}
+	p.cond = sync.NewCond(p)
+	return p
+}
+
+func (p *muxPool) Close() error {
+	p.Lock()
+	var conns []*muxConn
+	for addr, c := range p.conns {
+		conns = append(conns, c...)
+		delete(p.conns, addr)
+	}
+	p.Unlock()
+
+	for _, c := range conns {
+		c.close(errConnClosed)
+	}
+
+	return nil
+}
+
+// Get returns a stream on the least loaded connection for the address.
+// A new connection is dialled while every connection is busy and there
+// are fewer than size connections.
+func (p *muxPool) Get(addr string, opts ...network.DialOption) (pool.Conn, error) {
+	p.Lock()
+
+	for {
+		conn, load := p.pick(addr)
+		total := len(p.conns[addr]) + p.dialing[addr]
+
+		// use an idle connection or the least loaded one when at capacity
+		if conn != nil && (load == 0 || total >= p.size) {
+			s := conn.newStream()
+			p.Unlock()
+			return s, nil
+		}
+
+		if total < p.size {
+			break
+		}
+
+		// wait for a connection being dialled
+		p.cond.Wait()
+	}
+
+	p.dialing[addr]++
+	p.Unlock()
+
+	c, err := p.tr.Dial(addr, opts...)
+
+	p.Lock()
+	defer p.Unlock()
+	defer p.cond.Broadcast()
+
+	p.dialing[addr]--
+	if p.dialing[addr] == 0 {
+		delete(p.dialing, addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &muxConn{
+		Client:  c,
+		pool:    p,
+		addr:    addr,
+		created: time.Now(),
+		streams: make(map[string]*muxStream),
+		closed:  make(chan bool),
+	}
+
+	go conn.read()
+
+	p.conns[addr] = append(p.conns[addr], conn)
+
+	return conn.newStream(), nil
+}
+
+// pick returns the least loaded connection for the address. Connections
+// past the ttl are drained. Called under the pool lock.
+func (p *muxPool) pick(addr string) (*muxConn, int) {
+	var conn *muxConn
+	var load int
+
+	conns := p.conns[addr][:0]
+
+	for _, c := range p.conns[addr] {
+		// skip connections which failed
+		if c.isClosed() {
+			continue
+		}
+
+		// stop handing out old connections, they're
+		// closed once the last stream is done
+		if time.Since(c.created) > p.ttl {
+			c.drain()
+			continue
+		}
+
+		conns = append(conns, c)
+
+		if n := c.load(); conn == nil || n < load {
+			conn = c
+			load = n
+		}
+	}
+
+	p.conns[addr] = conns
+
+	return conn, load
+}
+
+// Release closes the stream. The connection is only closed on failure by its reader.
+func (p *muxPool) Release(c pool.Conn, err error) error {
+	return c.Close()
+}
+
+// remove the connection from the pool
+func (p *muxPool) remove(c *muxConn) {
+	p.Lock()
+	defer p.Unlock()
+
+	conns := p.conns[c.addr]
+	for i, conn := range conns {
+		if conn == c {
+			p.conns[c.addr] = append(conns[:i], conns[i+1:]...)
+			break
+		}
+	}
+
+	if len(p.conns[c.addr]) == 0 {
+		delete(p.conns, c.addr)
+	}
+}
+
+// load returns the number of open streams on the connection
+func (c *muxConn) load() int {
+	c.RLock()
+	defer c.RUnlock()
+	return c.active
+}
+
+func (c *muxConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *muxConn) newStream() *muxStream {
+	c.Lock()
+	c.active++
+	c.Unlock()
+
+	return &muxStream{
+		conn:   c,
+		id:     uuid.New().String(),
+		recv:   make(chan *network.Message, 1),
+		closed: make(chan bool),
+	}
+}
+
+// read demultiplexes messages to streams until the connection fails
+func (c *muxConn) read() {
+	for {
+		var msg network.Message
+		if err := c.Client.Recv(&msg); err != nil {
+			c.close(err)
+			return
+		}
+
+		c.RLock()
+		s, ok := c.streams[msg.Header["Id"]]
+		c.RUnlock()
+
+		// the stream was closed e.g the call timed out
+		if !ok {
+			continue
+		}
+
+		select {
+		case s.recv <- &msg:
+		case <-s.closed:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *muxConn) send(m *network.Message) error {
+	c.wmtx.Lock()
+	defer c.wmtx.Unlock()
+
+	select {
+	case <-c.closed:
+		return c.error()
+	default:
+	}
+
+	if err := c.Client.Send(m); err != nil {
+		c.close(err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *muxConn) error() error {
+	c.RLock()
+	defer c.RUnlock()
+	return c.err
+}
+
+// register the stream to receive messages with the id
+func (c *muxConn) register(s *muxStream, id string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	select {
+	case <-c.closed:
+		return c.err
+	default:
+	}
+
+	c.streams[id] = s
+	return nil
+}
+
+// done is called when a stream is closed
+func (c *muxConn) done(id string) {
+	c.Lock()
+	if len(id) > 0 {
+		delete(c.streams, id)
+	}
+	c.active--
+	idle := c.draining && c.active == 0
+	c.Unlock()
+
+	if idle {
+		c.close(errConnClosed)
+	}
+}
+
+// drain closes the connection once the last stream is done. Called under the pool lock.
+func (c *muxConn) drain() {
+	c.Lock()
+	c.draining = true
+	idle := c.active == 0
+	c.Unlock()
+
+	if idle {
+		go c.close(errConnClosed)
+	}
+}
+
+// close the connection and fail all its streams
+func (c *muxConn) close(err error) {
+	c.Lock()
+	select {
+	case <-c.closed:
+		c.Unlock()
+		return
+	default:
+		c.err = err
+		close(c.closed)
+	}
+	c.Unlock()
+
+	c.pool.remove(c)
+	c.Client.Close()
+}
+
+func (s *muxStream) Id() string {
+	return s.id
+}
+
+func (s *muxStream) Created() time.Time {
+	return s.conn.created
+}
+
+func (s *muxStream) Local() string {
+	return s.conn.Local()
+}
+
+func (s *muxStream) Remote() string {
+	return s.conn.Remote()
+}
+
+// Send registers the stream by the Id header of the first message and sends it
+func (s *muxStream) Send(m *network.Message) error {
+	s.Lock()
+	select {
+	case <-s.closed:
+		s.Unlock()
+		return errConnClosed
+	default:
+	}
+
+	if len(s.key) == 0 {
+		id := m.Header["Id"]
+		if err := s.conn.register(s, id); err != nil {
+			s.Unlock()
+			return err
+		}
+		s.key = id
+	}
+	s.Unlock()
+
+	return s.conn.send(m)
+}
+
+func (s *muxStream) Recv(m *network.Message) error {
+	select {
+	case msg := <-s.recv:
+		*m = *msg
+		return nil
+	case <-s.closed:
+		return errConnClosed
+	case <-s.conn.closed:
+		// deliver anything received before the connection closed
+		select {
+		case msg := <-s.recv:
+			*m = *msg
+			return nil
+		default:
+		}
+		return s.conn.error()
+	}
+}
+
+// Close the stream leaving the connection open for other streams
+func (s *muxStream) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	select {
+	case <-s.closed:
+		return nil
+	default:
+		close(s.closed)
+	}
+
+	s.conn.done(s.key)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/network"
+	"github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/server"
+	rpcServer "github.com/gonitro/nitro/app/server/rpc"
+)
+
+type EchoRequest struct {
+	Value string
+}
+
+type EchoResponse struct {
+	Value string
+}
+
+type Echo struct{}
+
+func (e *Echo) Echo(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	// hold the call open so they overlap
+	time.Sleep(time.Millisecond * 10)
+	rsp.Value = req.Value
+	return nil
+}
+
+// dialTransport counts the connections dialled
+type dialTransport struct {
+	network.Transport
+	dials int32
+}
+
+func (d *dialTransport) Dial(addr string, opts ...network.DialOption) (network.Client, error) {
+	atomic.AddInt32(&d.dials, 1)
+	return d.Transport.Dial(addr, opts...)
+}
+
+func TestCallMultiplex(t *testing.T) {
+	tr := socket.NewTransport()
+
+	s := rpcServer.NewServer(
+		server.Name("echo"),
+		server.Address("127.0.0.1:0"),
+		server.Transport(tr),
+	)
+	if err := s.Handle(s.NewHandler(new(Echo))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	dt := &dialTransport{Transport: tr}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Transport(dt),
+		client.Local(false),
+		client.PoolConns(2),
+	)
+
+	addr := s.Options().Address
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			value := fmt.Sprintf("echo-%d", i)
+			req := c.NewRequest("echo", "Echo.Echo", &EchoRequest{Value: value})
+			rsp := new(EchoResponse)
+
+			if err := c.Call(context.TODO(), req, rsp, client.WithAddress(addr)); err != nil {
+				errs <- err
+				return
+			}
+			if rsp.Value != value {
+				errs <- fmt.Errorf("expected %s got %s", value, rsp.Value)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&dt.dials); n > 2 {
+		t.Fatalf("expected at most 2 connections got %d", n)
+	}
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/server"
+	rpcServer "github.com/gonitro/nitro/app/server/rpc"
+)
+
+func TestCallRetryAfter(t *testing.T) {
+	for _, local := range []bool{false, true} {
+		testCallRetryAfter(t, local)
+	}
+}
+
+func testCallRetryAfter(t *testing.T, local bool) {
+	retryAfter := time.Millisecond * 300
+
+	tr := socket.NewTransport()
+
+	s := rpcServer.NewServer(
+		server.Name("echo"),
+		server.Address("127.0.0.1:0"),
+		server.Transport(tr),
+		server.MaxConcurrency(1),
+		server.RetryAfter(retryAfter),
+	)
+	if err := s.Handle(s.NewHandler(new(Echo))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Transport(tr),
+		client.Local(local),
+		client.Retries(1),
+	)
+
+	addr := s.Options().Address
+
+	var wg sync.WaitGroup
+	durations := make(chan time.Duration, 2)
+
+	// the second call is shed and retried once the server says
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			req := c.NewRequest("echo", "Echo.Echo", &EchoRequest{Value: "hello"})
+			if err := c.Call(context.TODO(), req, new(EchoResponse), client.WithAddress(addr)); err != nil {
+				t.Error(err)
+			}
+			durations <- time.Since(start)
+		}()
+	}
+
+	wg.Wait()
+	close(durations)
+
+	var waited bool
+	for d := range durations {
+		if d >= retryAfter {
+			waited = true
+		}
+	}
+	if !waited {
+		t.Fatalf("expected a call to be shed and retried after the server's delay with local %v", local)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gonitro/nitro/app/client"
 	"github.com/gonitro/nitro/app/codec"
+	"github.com/gonitro/nitro/app/network"
 )
 
 // Implements the streamer interface
@@ -96,6 +97,11 @@ func (r *rpcStream) Recv(msg interface{}) error {
 		return err
 	}
 
+	// keep the header of the last response
+	if rsp, ok := r.response.(*rpcResponse); ok {
+		rsp.header = resp.Header
+	}
+
 	switch {
 	case len(resp.Error) > 0:
 		// We've got an error response. Give this to the request;
@@ -124,6 +130,30 @@ func (r *rpcStream) Recv(msg interface{}) error {
 	return r.err
 }
 
+// cancel sends a control message telling the server to cancel the
+// context of the request. It's sent under the lock so it's not
+// interleaved with a call to Send.
+func (r *rpcStream) cancel() {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.isClosed() {
+		return
+	}
+
+	c, ok := r.codec.(*rpcCodec)
+	if !ok {
+		return
+	}
+
+	c.client.Send(&network.Message{
+		Header: map[string]string{
+			"Id":      r.id,
+			"Control": "cancel",
+		},
+	})
+}
+
 func (r *rpcStream) Error() error {
 	r.RLock()
 	defer r.RUnlock()
@@ -163,3 +193,11 @@ func (r *rpcStream) Close() error {
 		return err
 	}
 }
+
+// wrapStream applies the stream wrappers to the stream. The first wrapper is the outermost.
+func wrapStream(s client.Stream, wrappers []client.StreamWrapper) client.Stream {
+	for i := len(wrappers); i > 0; i-- {
+		s = wrappers[i-1](s)
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/server"
+	rpcServer "github.com/gonitro/nitro/app/server/rpc"
+)
+
+// Streamer echoes each message received on the stream
+type Streamer struct{}
+
+func (s *Streamer) Echo(ctx context.Context, stream server.Stream) error {
+	for {
+		req := new(EchoRequest)
+		if err := stream.Recv(req); err != nil {
+			return err
+		}
+		if err := stream.Send(&EchoResponse{Value: req.Value}); err != nil {
+			return err
+		}
+	}
+}
+
+// countServerStream counts the messages received by the server
+type countServerStream struct {
+	server.Stream
+	count *int32
+}
+
+func (s *countServerStream) Recv(msg interface{}) error {
+	atomic.AddInt32(s.count, 1)
+	return s.Stream.Recv(msg)
+}
+
+// countClientStream counts the messages sent by the client
+type countClientStream struct {
+	client.Stream
+	count *int32
+}
+
+func (s *countClientStream) Send(msg interface{}) error {
+	atomic.AddInt32(s.count, 1)
+	return s.Stream.Send(msg)
+}
+
+func TestStreamWrappers(t *testing.T) {
+	var recvd, sent int32
+
+	s := rpcServer.NewServer(
+		server.Name("streamer"),
+		server.Address("127.0.0.1:0"),
+		server.Transport(socket.NewTransport()),
+		server.WrapStream(func(s server.Stream) server.Stream {
+			return &countServerStream{Stream: s, count: &recvd}
+		}),
+	)
+	if err := s.Handle(s.NewHandler(new(Streamer))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.Transport(socket.NewTransport()),
+		client.WrapStream(func(s client.Stream) client.Stream {
+			return &countClientStream{Stream: s, count: &sent}
+		}),
+	)
+
+	req := c.NewRequest("streamer", "Streamer.Echo", &EchoRequest{}, client.StreamingRequest())
+	stream, err := c.Stream(context.TODO(), req, client.WithAddress(s.Options().Address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	for _, value := range []string{"a", "b"} {
+		if err := stream.Send(&EchoRequest{Value: value}); err != nil {
+			t.Fatal(err)
+		}
+		rsp := new(EchoResponse)
+		if err := stream.Recv(rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Value != value {
+			t.Fatalf("expected %s got %s", value, rsp.Value)
+		}
+	}
+
+	if n := atomic.LoadInt32(&sent); n != 2 {
+		t.Fatalf("expected client wrapper to see 2 sends got %d", n)
+	}
+	// the first message is the initial request
+	if n := atomic.LoadInt32(&recvd); n < 2 {
+		t.Fatalf("expected server wrapper to see the messages received got %d", n)
+	}
+}
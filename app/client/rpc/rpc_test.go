@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/gonitro/nitro/app/client"
 	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
 	"github.com/gonitro/nitro/app/registry"
 	"github.com/gonitro/nitro/app/registry/memory"
 	"github.com/gonitro/nitro/app/router"
@@ -157,3 +159,111 @@ func TestCallWrapper(t *testing.T) {
 		t.Fatal("wrapper not called")
 	}
 }
+
+func TestCallHedging(t *testing.T) {
+	service := "test.service"
+	endpoint := "Test.Endpoint"
+	slow := "10.1.10.1:8080"
+	fast := "10.1.10.2:8080"
+
+	cancelled := make(chan bool, 1)
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			hedge, _ := metadata.Get(ctx, client.HedgeHeader)
+
+			if node == slow {
+				if len(hedge) > 0 {
+					return fmt.Errorf("expected first request not to be hedged")
+				}
+				<-ctx.Done()
+				cancelled <- true
+				return errors.Timeout("test.error", "cancelled")
+			}
+
+			if hedge != "1" {
+				return fmt.Errorf("expected hedge header 1 got %q", hedge)
+			}
+
+			*rsp.(*string) = node
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(newTestRouter()),
+		client.WrapCall(wrap),
+	)
+
+	var rsp string
+
+	req := c.NewRequest(service, endpoint, nil)
+	err := c.Call(context.Background(), req, &rsp,
+		client.WithAddress(slow, fast),
+		client.WithHedging(time.Millisecond*10, 1),
+	)
+	if err != nil {
+		t.Fatal("hedged call error", err)
+	}
+
+	if rsp != fast {
+		t.Fatalf("expected response from %s got %s", fast, rsp)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow request not cancelled")
+	}
+}
+
+func TestCallHedgingLatency(t *testing.T) {
+	service := "test.service"
+	endpoint := "Test.Endpoint"
+	slow := "10.1.10.1:8080"
+	fast := "10.1.10.2:8080"
+
+	rtr := newTestRouter()
+
+	// the endpoint usually responds within 50ms
+	for i := 0; i < router.MinSamples; i++ {
+		rtr.(router.Latencies).Observe(service+"/"+endpoint, time.Millisecond*50)
+	}
+
+	start := time.Now()
+	hedged := make(chan time.Duration, 1)
+
+	wrap := func(cf client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node string, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			if node == slow {
+				<-ctx.Done()
+				return errors.Timeout("test.error", "cancelled")
+			}
+
+			hedged <- time.Since(start)
+			*rsp.(*string) = node
+			return nil
+		}
+	}
+
+	c := NewClient(
+		client.Router(rtr),
+		client.WrapCall(wrap),
+	)
+
+	var rsp string
+
+	req := c.NewRequest(service, endpoint, nil)
+	err := c.Call(context.Background(), req, &rsp,
+		client.WithAddress(slow, fast),
+		client.WithHedging(time.Millisecond, 1),
+	)
+	if err != nil {
+		t.Fatal("hedged call error", err)
+	}
+
+	// the hedge waits for the endpoint latency rather than the fallback delay
+	if d := <-hedged; d < time.Millisecond*40 {
+		t.Fatalf("expected hedged request after the endpoint latency got %v", d)
+	}
+}
@@ -0,0 +1,414 @@
+// Package config loads declarative app configuration from a file and the environment.
+//
+// Values are resolved in order of precedence, highest first:
+//
+//  1. options passed in code after app.Config e.g app.Name
+//  2. NITRO_* environment variables e.g NITRO_CLIENT_RETRIES
+//  3. the config file in JSON, YAML or TOML format
+//  4. the defaults of the app
+//
+// Options are applied in order so options passed before app.Config are
+// overridden by the keys which are set. In an app.Host the broker, registry,
+// router and transport keys are ignored as those of the host are shared.
+//
+// Keys are lower case and nested keys are joined by a dot e.g client.retries.
+// The matching environment variable is the key in upper case with dots replaced
+// by underscores and prefixed by NITRO_.
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonitro/nitro/app/logger"
+)
+
+var (
+	// EnvPrefix is the prefix of environment variables
+	EnvPrefix = "NITRO_"
+	// EnvFile is the environment variable used to set the config file
+	EnvFile = "NITRO_CONFIG"
+
+	// Brokers are the valid values of the broker key
+	Brokers = []string{"memory"}
+	// Registries are the valid values of the registry key
+	Registries = []string{"memory"}
+	// Routers are the valid values of the router key
+	Routers = []string{"dns", "registry", "static"}
+	// Transports are the valid values of the transport key
+	Transports = []string{"memory", "socket"}
+)
+
+// Config is the declarative configuration of an app
+type Config struct {
+	Name      string `config:"name"`
+	Version   string `config:"version"`
+	Address   string `config:"address"`
+	Broker    string `config:"broker"`
+	Registry  string `config:"registry"`
+	Router    string `config:"router"`
+	Transport string `config:"transport"`
+	LogLevel  string `config:"log_level"`
+
+	TLS    TLS    `config:"tls"`
+	Client Client `config:"client"`
+	Server Server `config:"server"`
+
+	// keys which have been set
+	set map[string]bool
+	// loaded from the tls files
+	tlsConfig *tls.Config
+}
+
+// TLS is the location of the certificates used to secure the transport. The
+// certificates of the ca_file are trusted to verify peers. Servers only require
+// clients to present a certificate signed by them if client_auth is set.
+type TLS struct {
+	CertFile   string `config:"cert_file"`
+	KeyFile    string `config:"key_file"`
+	CAFile     string `config:"ca_file"`
+	ClientAuth bool   `config:"client_auth"`
+}
+
+// Client is the configuration of the client
+type Client struct {
+	Retries        int           `config:"retries"`
+	PoolSize       int           `config:"pool_size"`
+	RequestTimeout time.Duration `config:"request_timeout"`
+	DialTimeout    time.Duration `config:"dial_timeout"`
+}
+
+// Server is the configuration of the server
+type Server struct {
+	AddTTL       time.Duration `config:"add_ttl"`
+	AddInterval  time.Duration `config:"add_interval"`
+	DrainTimeout time.Duration `config:"drain_timeout"`
+}
+
+// Error is a validation error for a single key
+type Error struct {
+	// Key which is invalid e.g client.retries
+	Key string
+	// Source of the value e.g the file or environment variable
+	Source string
+	// Err describing why the value is invalid
+	Err error
+}
+
+func (e *Error) Error() string {
+	if len(e.Source) == 0 {
+		return fmt.Sprintf("config: %s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("config: %s (from %s): %v", e.Key, e.Source, e.Err)
+}
+
+// value is a raw value and where it came from
+type value struct {
+	val    string
+	source string
+}
+
+// IsSet returns true if the key was set in the file or environment
+func (c *Config) IsSet(key string) bool {
+	return c.set[key]
+}
+
+// TLSConfig returns the tls config loaded from the tls files or nil if not set
+func (c *Config) TLSConfig() *tls.Config {
+	return c.tlsConfig
+}
+
+// Keys returns every valid config key
+func Keys() []string {
+	var keys []string
+	walk(reflect.ValueOf(&Config{}).Elem(), "", func(key string, _ reflect.Value) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// Env returns the environment variable for a key e.g NITRO_CLIENT_RETRIES
+func Env(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// Load reads the config file at path followed by the environment. If path
+// is blank the file is read from NITRO_CONFIG, if that is set. The format
+// of the file is determined by its extension: .json, .yaml, .yml or .toml.
+func Load(path string) (*Config, error) {
+	values := make(map[string]value)
+
+	if len(path) == 0 {
+		path = os.Getenv(EnvFile)
+	}
+
+	if len(path) > 0 {
+		kv, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range kv {
+			values[k] = value{v, path}
+		}
+	}
+
+	// the environment overrides the file
+	for _, key := range Keys() {
+		env := Env(key)
+		if v, ok := os.LookupEnv(env); ok {
+			values[key] = value{v, env}
+		}
+	}
+
+	return decode(values)
+}
+
+// Parse the config data in the given format, one of json, yaml or toml.
+// The environment is not read.
+func Parse(data []byte, format string) (*Config, error) {
+	kv, err := parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]value, len(kv))
+	for k, v := range kv {
+		values[k] = value{v, format}
+	}
+
+	return decode(values)
+}
+
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %v", err)
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+
+	kv, err := parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %v", path, err)
+	}
+
+	return kv, nil
+}
+
+func parse(data []byte, format string) (map[string]string, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		return parseJSON(data)
+	case "yaml", "yml":
+		return parseYAML(data)
+	case "toml":
+		return parseTOML(data)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// walk calls fn for every field of the config with its key
+func walk(v reflect.Value, prefix string, fn func(key string, field reflect.Value)) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("config")
+		if len(tag) == 0 {
+			continue
+		}
+
+		key := prefix + tag
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			walk(field, key+".", fn)
+			continue
+		}
+
+		fn(key, field)
+	}
+}
+
+// decode sets the values on a new config and validates it
+func decode(values map[string]value) (*Config, error) {
+	c := &Config{
+		set: make(map[string]bool),
+	}
+
+	fields := make(map[string]reflect.Value)
+	walk(reflect.ValueOf(c).Elem(), "", func(key string, field reflect.Value) {
+		fields[key] = field
+	})
+
+	// decode in order so the first invalid key is always the one reported
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := values[key]
+		field, ok := fields[key]
+		if !ok {
+			return nil, &Error{Key: key, Source: v.source, Err: fmt.Errorf("unknown key")}
+		}
+
+		if err := setField(field, v.val); err != nil {
+			return nil, &Error{Key: key, Source: v.source, Err: err}
+		}
+
+		c.set[key] = true
+	}
+
+	source := func(key string) string {
+		return values[key].source
+	}
+
+	choices := []struct {
+		key   string
+		val   string
+		valid []string
+	}{
+		{"broker", c.Broker, Brokers},
+		{"registry", c.Registry, Registries},
+		{"router", c.Router, Routers},
+		{"transport", c.Transport, Transports},
+	}
+
+	for _, ch := range choices {
+		if !c.set[ch.key] || contains(ch.valid, ch.val) {
+			continue
+		}
+		return nil, &Error{
+			Key:    ch.key,
+			Source: source(ch.key),
+			Err:    fmt.Errorf("unknown value %q, must be one of %s", ch.val, strings.Join(ch.valid, ", ")),
+		}
+	}
+
+	if c.set["log_level"] {
+		if _, err := logger.GetLevel(c.LogLevel); err != nil {
+			return nil, &Error{Key: "log_level", Source: source("log_level"), Err: err}
+		}
+	}
+
+	if c.set["client.retries"] && c.Client.Retries < 0 {
+		return nil, &Error{Key: "client.retries", Source: source("client.retries"), Err: fmt.Errorf("must not be negative")}
+	}
+
+	if err := c.loadTLS(source); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func setField(field reflect.Value, v string) error {
+	v = strings.TrimSpace(v)
+
+	// durations are either a string e.g 10s or nanoseconds
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			i, ierr := strconv.ParseInt(v, 10, 64)
+			if ierr != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			d = time.Duration(i)
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid duration %q, must not be negative", v)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(v)
+	case reflect.Int:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		field.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// loadTLS reads the certificates from the tls files
+func (c *Config) loadTLS(source func(string) string) error {
+	t := c.TLS
+
+	if len(t.CertFile) == 0 && len(t.KeyFile) == 0 && len(t.CAFile) == 0 && !t.ClientAuth {
+		return nil
+	}
+
+	switch {
+	case len(t.CertFile) == 0 && len(t.KeyFile) > 0:
+		return &Error{Key: "tls.cert_file", Err: fmt.Errorf("must be set with tls.key_file")}
+	case len(t.KeyFile) == 0 && len(t.CertFile) > 0:
+		return &Error{Key: "tls.key_file", Err: fmt.Errorf("must be set with tls.cert_file")}
+	case len(t.CAFile) == 0 && t.ClientAuth:
+		return &Error{Key: "tls.ca_file", Err: fmt.Errorf("must be set with tls.client_auth")}
+	}
+
+	config := &tls.Config{}
+
+	if len(t.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return &Error{Key: "tls.cert_file", Source: source("tls.cert_file"), Err: err}
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(t.CAFile) > 0 {
+		ca, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return &Error{Key: "tls.ca_file", Source: source("tls.ca_file"), Err: err}
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return &Error{Key: "tls.ca_file", Source: source("tls.ca_file"), Err: fmt.Errorf("no certificates found")}
+		}
+		config.RootCAs = pool
+		config.ClientCAs = pool
+	}
+
+	if t.ClientAuth {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	c.tlsConfig = config
+	return nil
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
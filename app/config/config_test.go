@@ -0,0 +1,196 @@
+package config
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mtls "github.com/gonitro/nitro/util/tls"
+)
+
+var testConfigs = map[string]string{
+	"json": `{
+	"name": "greeter",
+	"version": "1.0.0",
+	"address": "127.0.0.1:8080",
+	"router": "registry",
+	"client": {
+		"retries": 3,
+		"request_timeout": "10s"
+	},
+	"server": {
+		"drain_timeout": "5s"
+	}
+}`,
+	"yaml": `# greeter config
+name: greeter
+version: "1.0.0"
+address: 127.0.0.1:8080 # listen locally
+router: registry
+client:
+  retries: 3
+  request_timeout: 10s
+server:
+  drain_timeout: 5s
+`,
+	"toml": `# greeter config
+name = "greeter"
+version = "1.0.0"
+address = "127.0.0.1:8080" # listen locally
+router = "registry"
+
+[client]
+retries = 3
+request_timeout = "10s"
+
+[server]
+drain_timeout = "5s"
+`,
+}
+
+func TestParse(t *testing.T) {
+	for format, data := range testConfigs {
+		c, err := Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if c.Name != "greeter" || c.Version != "1.0.0" || c.Address != "127.0.0.1:8080" || c.Router != "registry" {
+			t.Fatalf("%s: unexpected config %+v", format, c)
+		}
+		if c.Client.Retries != 3 || c.Client.RequestTimeout != time.Second*10 {
+			t.Fatalf("%s: unexpected client config %+v", format, c.Client)
+		}
+		if c.Server.DrainTimeout != time.Second*5 {
+			t.Fatalf("%s: unexpected server config %+v", format, c.Server)
+		}
+		if !c.IsSet("client.retries") || c.IsSet("client.dial_timeout") {
+			t.Fatalf("%s: unexpected keys set %v", format, c.set)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nitro.yaml")
+	if err := ioutil.WriteFile(path, []byte(testConfigs["yaml"]), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("NITRO_NAME", "helloworld")
+	os.Setenv("NITRO_CLIENT_RETRIES", "5")
+	defer os.Unsetenv("NITRO_NAME")
+	defer os.Unsetenv("NITRO_CLIENT_RETRIES")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment takes precedence over the file
+	if c.Name != "helloworld" {
+		t.Fatalf("Expected name helloworld got %s", c.Name)
+	}
+	if c.Client.Retries != 5 {
+		t.Fatalf("Expected 5 retries got %d", c.Client.Retries)
+	}
+	if c.Version != "1.0.0" {
+		t.Fatalf("Expected version 1.0.0 got %s", c.Version)
+	}
+
+	// invalid env values name the variable
+	os.Setenv("NITRO_CLIENT_RETRIES", "five")
+
+	_, err = Load(path)
+	if err == nil || !strings.Contains(err.Error(), "client.retries") || !strings.Contains(err.Error(), "NITRO_CLIENT_RETRIES") {
+		t.Fatalf("Expected error naming client.retries from NITRO_CLIENT_RETRIES got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testData := []struct {
+		data string
+		key  string
+	}{
+		{`{"registry": "etcd"}`, "registry"},
+		{`{"router": "mdns"}`, "router"},
+		{`{"log_level": "loud"}`, "log_level"},
+		{`{"client": {"retries": -1}}`, "client.retries"},
+		{`{"client": {"request_timeout": "soon"}}`, "client.request_timeout"},
+		{`{"server": {"ttl": "10s"}}`, "server.ttl"},
+		{`{"tls": {"key_file": "key.pem"}}`, "tls.cert_file"},
+		{`{"tls": {"cert_file": "missing.pem", "key_file": "missing.pem"}}`, "tls.cert_file"},
+		{`{"tls": {"client_auth": true}}`, "tls.ca_file"},
+		{`{"tls": {"client_auth": "maybe"}}`, "tls.client_auth"},
+		// the first of many invalid keys is reported
+		{`{"server": {"ttl": "10s"}, "client": {"ttl": "1s"}, "ttl": "1s"}`, "client.ttl"},
+	}
+
+	for _, d := range testData {
+		_, err := Parse([]byte(d.data), "json")
+		if err == nil {
+			t.Fatalf("Expected error for %s", d.data)
+		}
+
+		cerr, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("Expected *Error for %s got %T %v", d.data, err, err)
+		}
+		if cerr.Key != d.key {
+			t.Fatalf("Expected error for key %s got %s: %v", d.key, cerr.Key, err)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	cert, err := mtls.Certificate("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		data string
+		auth tls.ClientAuthType
+	}{
+		// a custom root ca doesn't require client certificates
+		{fmt.Sprintf(`{"tls": {"ca_file": %q}}`, ca), tls.NoClientCert},
+		{fmt.Sprintf(`{"tls": {"ca_file": %q, "client_auth": true}}`, ca), tls.RequireAndVerifyClientCert},
+	}
+
+	for _, d := range testData {
+		c, err := Parse([]byte(d.data), "json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config := c.TLSConfig()
+		if config == nil || config.RootCAs == nil || config.ClientCAs == nil {
+			t.Fatalf("Expected the ca to be loaded got %+v", config)
+		}
+		if config.ClientAuth != d.auth {
+			t.Fatalf("Expected client auth %v got %v", d.auth, config.ClientAuth)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The parsers below flatten a file into dotted keys and string values. Only
+// the subset of each format needed to express the config is supported: nested
+// maps and scalar values. Lists are rejected.
+
+func parseJSON(data []byte) (map[string]string, error) {
+	var m map[string]interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	kv := make(map[string]string)
+	if err := flatten("", m, kv); err != nil {
+		return nil, err
+	}
+
+	return kv, nil
+}
+
+func flatten(prefix string, m map[string]interface{}, kv map[string]string) error {
+	for k, v := range m {
+		key := prefix + k
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if err := flatten(key+".", val, kv); err != nil {
+				return err
+			}
+		case string:
+			kv[key] = val
+		case json.Number:
+			kv[key] = val.String()
+		case bool:
+			kv[key] = strconv.FormatBool(val)
+		case nil:
+		default:
+			return fmt.Errorf("%s: unsupported value %v", key, v)
+		}
+	}
+
+	return nil
+}
+
+func parseYAML(data []byte) (map[string]string, error) {
+	kv := make(map[string]string)
+
+	// parents of the current line by indentation
+	type parent struct {
+		indent int
+		prefix string
+	}
+	var stack []parent
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := stripComment(scanner.Text())
+
+		if len(strings.TrimSpace(line)) == 0 || strings.TrimSpace(line) == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n)
+		}
+
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			return nil, fmt.Errorf("line %d: lists are not supported", n)
+		}
+
+		parts := strings.SplitN(trimmed, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected key: value", n)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		// pop the parents this line is no longer nested in
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		prefix := ""
+		if len(stack) > 0 {
+			prefix = stack[len(stack)-1].prefix
+		}
+
+		// start of a nested map
+		if len(val) == 0 {
+			stack = append(stack, parent{indent, prefix + key + "."})
+			continue
+		}
+
+		v, err := unquote(val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		kv[prefix+key] = v
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return kv, nil
+}
+
+func parseTOML(data []byte) (map[string]string, error) {
+	kv := make(map[string]string)
+	prefix := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+
+		if len(line) == 0 {
+			continue
+		}
+
+		// start of a table
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table %s", n, line)
+			}
+			table := strings.TrimSpace(line[1 : len(line)-1])
+			if len(table) == 0 {
+				return nil, fmt.Errorf("line %d: empty table name", n)
+			}
+			prefix = table + "."
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		if strings.HasPrefix(val, "[") || strings.HasPrefix(val, "{") {
+			return nil, fmt.Errorf("line %d: arrays and inline tables are not supported", n)
+		}
+
+		v, err := unquote(val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		kv[prefix+key] = v
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return kv, nil
+}
+
+// stripComment removes a trailing # comment which is not within quotes
+func stripComment(line string) string {
+	var quote rune
+
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// unquote a scalar value which may be double or single quoted
+func unquote(v string) (string, error) {
+	if len(v) < 2 {
+		return v, nil
+	}
+
+	switch {
+	case v[0] == '"' && v[len(v)-1] == '"':
+		return strconv.Unquote(v)
+	case v[0] == '\'' && v[len(v)-1] == '\'':
+		return v[1 : len(v)-1], nil
+	case v[0] == '"' || v[0] == '\'':
+		return "", fmt.Errorf("unterminated string %s", v)
+	}
+
+	return v, nil
+}
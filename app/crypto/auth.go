@@ -2,6 +2,7 @@
 package crypto
 
 import (
+	"context"
 	"errors"
 	"time"
 )
@@ -118,3 +119,16 @@ type Rule struct {
 	// rule will be applied
 	Priority int32
 }
+
+type accountKey struct{}
+
+// ContextWithAccount sets the account in the context
+func ContextWithAccount(ctx context.Context, acc *Account) context.Context {
+	return context.WithValue(ctx, accountKey{}, acc)
+}
+
+// AccountFromContext returns the account set in the context
+func AccountFromContext(ctx context.Context) (*Account, bool) {
+	acc, ok := ctx.Value(accountKey{}).(*Account)
+	return acc, ok
+}
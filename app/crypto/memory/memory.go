@@ -0,0 +1,61 @@
+// Package memory provides rules held in memory
+package memory
+
+import (
+	"sync"
+
+	"github.com/gonitro/nitro/app/crypto"
+)
+
+type memoryRules struct {
+	sync.RWMutex
+	rules map[string]*crypto.Rule
+}
+
+// NewRules returns rules held in memory which are verified with crypto.VerifyAccess
+func NewRules(rules ...*crypto.Rule) crypto.Rules {
+	r := &memoryRules{
+		rules: make(map[string]*crypto.Rule),
+	}
+
+	for _, rule := range rules {
+		r.rules[rule.ID] = rule
+	}
+
+	return r
+}
+
+// Grant access to a resource
+func (m *memoryRules) Grant(rule *crypto.Rule) error {
+	m.Lock()
+	m.rules[rule.ID] = rule
+	m.Unlock()
+	return nil
+}
+
+// Revoke access to a resource
+func (m *memoryRules) Revoke(rule *crypto.Rule) error {
+	m.Lock()
+	delete(m.rules, rule.ID)
+	m.Unlock()
+	return nil
+}
+
+// List returns all the rules
+func (m *memoryRules) List(opts ...crypto.RulesOption) ([]*crypto.Rule, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	rules := make([]*crypto.Rule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// Verify an account has access to a resource
+func (m *memoryRules) Verify(acc *crypto.Account, res *crypto.Resource, opts ...crypto.VerifyOption) error {
+	rules, _ := m.List()
+	return crypto.VerifyAccess(rules, acc, res)
+}
@@ -0,0 +1,306 @@
+// Package debug provides the internal handler used to diagnose a running server
+package debug
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/gonitro/nitro/app/server"
+	"github.com/gonitro/nitro/util/ring"
+)
+
+// Server is the server being debugged
+type Server interface {
+	Options() server.Options
+	// Handlers registered with the server
+	Handlers() []server.Handler
+	// Subscribers registered with the server
+	Subscribers() []server.Subscriber
+}
+
+// Request is empty as the endpoints take no arguments
+type Request struct{}
+
+// StatsResponse holds the runtime stats of the server
+type StatsResponse struct {
+	// Started is the unix time the server was created
+	Started int64 `json:"started"`
+	// Uptime in seconds
+	Uptime int64 `json:"uptime"`
+	// Goroutines running
+	Goroutines int `json:"goroutines"`
+	// Memory allocated on the heap in bytes
+	Memory uint64 `json:"memory"`
+	// System memory obtained from the OS in bytes
+	System uint64 `json:"system"`
+	// GC is the number of completed GC cycles
+	GC uint32 `json:"gc"`
+	// Requests served in total
+	Requests uint64 `json:"requests"`
+	// Errors returned in total
+	Errors uint64 `json:"errors"`
+	// Endpoints holds the stats of each endpoint called
+	Endpoints []*Endpoint `json:"endpoints"`
+}
+
+// Endpoint stats
+type Endpoint struct {
+	Name     string `json:"name"`
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+	// AvgLatency in nanoseconds
+	AvgLatency int64 `json:"avg_latency"`
+	// MaxLatency in nanoseconds
+	MaxLatency int64 `json:"max_latency"`
+}
+
+// HandlersResponse lists the handlers and subscribers of the server
+type HandlersResponse struct {
+	Handlers    []*Handler    `json:"handlers"`
+	Subscribers []*Subscriber `json:"subscribers"`
+}
+
+// Handler registered with the server
+type Handler struct {
+	Name      string   `json:"name"`
+	Endpoints []string `json:"endpoints"`
+	Internal  bool     `json:"internal"`
+}
+
+// Subscriber registered with the server
+type Subscriber struct {
+	Event     string   `json:"event"`
+	Queue     string   `json:"queue"`
+	Endpoints []string `json:"endpoints"`
+	Internal  bool     `json:"internal"`
+}
+
+// OptionsResponse holds the current options of the server
+type OptionsResponse struct {
+	Name                string            `json:"name"`
+	Id                  string            `json:"id"`
+	Version             string            `json:"version"`
+	Namespace           string            `json:"namespace"`
+	Address             string            `json:"address"`
+	Advertise           string            `json:"advertise"`
+	Metadata            map[string]string `json:"metadata"`
+	Transport           string            `json:"transport"`
+	Broker              string            `json:"broker"`
+	Registry            string            `json:"registry"`
+	Auth                string            `json:"auth"`
+	AddTTL              string            `json:"add_ttl"`
+	AddInterval         string            `json:"add_interval"`
+	DrainTimeout        string            `json:"drain_timeout"`
+	MaxConcurrency      int               `json:"max_concurrency"`
+	EndpointConcurrency map[string]int    `json:"endpoint_concurrency"`
+	QueueWait           string            `json:"queue_wait"`
+}
+
+// Record is a log entry
+type Record struct {
+	// Timestamp in unix nanoseconds
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// Debug is the internal handler used to diagnose a running server.
+// It's registered as an internal handler so it's not advertised.
+type Debug struct {
+	srv   Server
+	stats *Stats
+	logs  *ring.Buffer
+}
+
+// NewHandler returns a new Debug handler for the server. The stats
+// are recorded by adding Stats.Wrap to the handler wrappers.
+func NewHandler(srv Server, stats *Stats) *Debug {
+	return &Debug{
+		srv:   srv,
+		stats: stats,
+		logs:  Logs(),
+	}
+}
+
+// Stats returns the runtime stats and the stats of each endpoint
+func (d *Debug) Stats(ctx context.Context, req *Request, rsp *StatsResponse) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	started := d.stats.Started()
+
+	rsp.Started = started.Unix()
+	rsp.Uptime = int64(time.Since(started).Seconds())
+	rsp.Goroutines = runtime.NumGoroutine()
+	rsp.Memory = mem.Alloc
+	rsp.System = mem.Sys
+	rsp.GC = mem.NumGC
+	rsp.Endpoints = d.stats.Endpoints()
+
+	for _, e := range rsp.Endpoints {
+		rsp.Requests += e.Requests
+		rsp.Errors += e.Errors
+	}
+
+	return nil
+}
+
+// Handlers lists the handlers and subscribers registered with the server
+func (d *Debug) Handlers(ctx context.Context, req *Request, rsp *HandlersResponse) error {
+	for _, h := range d.srv.Handlers() {
+		hdlr := &Handler{
+			Name:     h.Name(),
+			Internal: h.Options().Internal,
+		}
+		for _, e := range h.Endpoints() {
+			hdlr.Endpoints = append(hdlr.Endpoints, e.Name)
+		}
+		rsp.Handlers = append(rsp.Handlers, hdlr)
+	}
+
+	for _, s := range d.srv.Subscribers() {
+		sub := &Subscriber{
+			Event:    s.Event(),
+			Queue:    s.Options().Queue,
+			Internal: s.Options().Internal,
+		}
+		for _, e := range s.Endpoints() {
+			sub.Endpoints = append(sub.Endpoints, e.Name)
+		}
+		rsp.Subscribers = append(rsp.Subscribers, sub)
+	}
+
+	sort.Slice(rsp.Handlers, func(i, j int) bool {
+		return rsp.Handlers[i].Name < rsp.Handlers[j].Name
+	})
+	sort.Slice(rsp.Subscribers, func(i, j int) bool {
+		return rsp.Subscribers[i].Event < rsp.Subscribers[j].Event
+	})
+
+	return nil
+}
+
+// Options returns the current options of the server
+func (d *Debug) Options(ctx context.Context, req *Request, rsp *OptionsResponse) error {
+	opts := d.srv.Options()
+
+	rsp.Name = opts.Name
+	rsp.Id = opts.Id
+	rsp.Version = opts.Version
+	rsp.Namespace = opts.Namespace
+	rsp.Address = opts.Address
+	rsp.Advertise = opts.Advertise
+	rsp.Metadata = opts.Metadata
+	rsp.AddTTL = opts.AddTTL.String()
+	rsp.AddInterval = opts.AddInterval.String()
+	rsp.DrainTimeout = opts.DrainTimeout.String()
+	rsp.MaxConcurrency = opts.MaxConcurrency
+	rsp.EndpointConcurrency = opts.EndpointConcurrency
+	rsp.QueueWait = opts.QueueWait.String()
+
+	if opts.Transport != nil {
+		rsp.Transport = opts.Transport.String()
+	}
+	if opts.Broker != nil {
+		rsp.Broker = opts.Broker.String()
+	}
+	if opts.Registry != nil {
+		rsp.Registry = opts.Registry.String()
+	}
+	if opts.Auth != nil {
+		rsp.Auth = opts.Auth.String()
+	}
+
+	return nil
+}
+
+// Log streams the recent log entries followed by every new entry. The
+// entries tagged with the AppField of another app in the process are skipped.
+func (d *Debug) Log(ctx context.Context, stream server.Stream) error {
+	entries, stop := d.logs.Stream()
+	defer close(stop)
+
+	// drain the entries so a slow client doesn't block logging
+	// and drop them if the client can't keep up
+	queue := make(chan *ring.Entry, d.logs.Size())
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case e := <-entries:
+				select {
+				case queue <- e:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// detect the client closing the stream
+	closed := make(chan bool)
+	go func() {
+		defer close(closed)
+		for {
+			var req Request
+			if err := stream.Recv(&req); err != nil {
+				return
+			}
+		}
+	}()
+
+	// entries logged since the stream started are also in the recent
+	// entries so they're only sent once
+	sent := make(map[*ring.Entry]bool)
+
+	for _, e := range d.logs.Get(d.logs.Size()) {
+		sent[e] = true
+		if !d.logged(e) {
+			continue
+		}
+		if err := stream.Send(newRecord(e)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case e := <-queue:
+			if sent[e] {
+				delete(sent, e)
+				continue
+			}
+			// the rest of the recent entries won't be streamed
+			sent = nil
+			if !d.logged(e) {
+				continue
+			}
+			if err := stream.Send(newRecord(e)); err != nil {
+				return err
+			}
+		case <-closed:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// logged returns true if the entry was logged by the server or by no app in particular
+func (d *Debug) logged(e *ring.Entry) bool {
+	msg, _ := e.Value.(string)
+	app := logApp(msg)
+	return len(app) == 0 || app == d.srv.Options().Name
+}
+
+func newRecord(e *ring.Entry) *Record {
+	msg, _ := e.Value.(string)
+	return &Record{
+		Timestamp: e.Timestamp.UnixNano(),
+		Message:   msg,
+	}
+}
@@ -0,0 +1,70 @@
+package debug
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/gonitro/nitro/app/logger"
+	"github.com/gonitro/nitro/util/ring"
+)
+
+var (
+	// DefaultLogSize is the number of recent log entries kept
+	DefaultLogSize = 1024
+	// AppField is the log field holding the name of the app an entry was
+	// logged by. The Log stream of an app skips the entries of other apps.
+	AppField = "app"
+
+	logs    *ring.Buffer
+	logOnce sync.Once
+)
+
+// Logs returns the buffer of recent log entries. The first call tees the
+// entries of the default loggers into the buffer so they're kept even if
+// the logger is replaced or its output changed.
+func Logs() *ring.Buffer {
+	logOnce.Do(func() {
+		logs = ring.New(DefaultLogSize)
+		logger.Tee(&logWriter{buf: logs})
+	})
+
+	return logs
+}
+
+// logWriter puts each line written into the buffer
+type logWriter struct {
+	buf *ring.Buffer
+}
+
+func (w *logWriter) Write(b []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(b, "\n"), []byte("\n")) {
+		if len(line) > 0 {
+			w.buf.Put(string(line))
+		}
+	}
+	return len(b), nil
+}
+
+// logApp returns the value of the AppField of a log line or an empty string.
+// The fields of a line are the key=value pairs following the timestamp.
+func logApp(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return ""
+	}
+
+	prefix := AppField + "="
+
+	// skip the date and time
+	for _, f := range fields[2:] {
+		if !strings.Contains(f, "=") {
+			break
+		}
+		if strings.HasPrefix(f, prefix) {
+			return strings.TrimPrefix(f, prefix)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,93 @@
+package debug
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/server"
+)
+
+// Stats records the requests served by a server
+type Stats struct {
+	started time.Time
+
+	sync.Mutex
+	endpoints map[string]*endpointStats
+}
+
+type endpointStats struct {
+	requests uint64
+	errors   uint64
+	total    time.Duration
+	max      time.Duration
+}
+
+// NewStats returns stats which are started now
+func NewStats() *Stats {
+	return &Stats{
+		started:   time.Now(),
+		endpoints: make(map[string]*endpointStats),
+	}
+}
+
+// Started returns the time the stats were started
+func (s *Stats) Started() time.Time {
+	return s.started
+}
+
+// Record a request to the endpoint
+func (s *Stats) Record(endpoint string, d time.Duration, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	e, ok := s.endpoints[endpoint]
+	if !ok {
+		e = new(endpointStats)
+		s.endpoints[endpoint] = e
+	}
+
+	e.requests++
+	if err != nil {
+		e.errors++
+	}
+	e.total += d
+	if d > e.max {
+		e.max = d
+	}
+}
+
+// Endpoints returns the stats of each endpoint sorted by name
+func (s *Stats) Endpoints() []*Endpoint {
+	s.Lock()
+	defer s.Unlock()
+
+	endpoints := make([]*Endpoint, 0, len(s.endpoints))
+
+	for name, e := range s.endpoints {
+		endpoints = append(endpoints, &Endpoint{
+			Name:       name,
+			Requests:   e.requests,
+			Errors:     e.errors,
+			AvgLatency: int64(e.total / time.Duration(e.requests)),
+			MaxLatency: int64(e.max),
+		})
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Name < endpoints[j].Name
+	})
+
+	return endpoints
+}
+
+// Wrap is a server.HandlerWrapper which records the requests served
+func (s *Stats) Wrap(fn server.HandlerFunc) server.HandlerFunc {
+	return func(ctx context.Context, req server.Request, rsp interface{}) error {
+		start := time.Now()
+		err := fn(ctx, req, rsp)
+		s.Record(req.Endpoint(), time.Since(start), err)
+		return err
+	}
+}
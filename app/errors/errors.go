@@ -111,6 +111,16 @@ func Conflict(id, format string, a ...interface{}) error {
 	}
 }
 
+// TooManyRequests generates a 429 error.
+func TooManyRequests(id, format string, a ...interface{}) error {
+	return &Error{
+		Id:     id,
+		Code:   http.StatusTooManyRequests,
+		Detail: fmt.Sprintf(format, a...),
+		Status: http.StatusText(http.StatusTooManyRequests),
+	}
+}
+
 // InternalServerError generates a 500 error.
 func InternalServerError(id, format string, a ...interface{}) error {
 	return &Error{
@@ -62,6 +62,13 @@ func (m *memoryBroker) Connect() error {
 	return nil
 }
 
+// Connected returns true if the broker is connected
+func (m *memoryBroker) Connected() bool {
+	m.RLock()
+	defer m.RUnlock()
+	return m.connected
+}
+
 func (m *memoryBroker) Disconnect() error {
 	m.Lock()
 	defer m.Unlock()
@@ -0,0 +1,368 @@
+// Package gateway serves the handlers of apps over HTTP as JSON. A request to
+// POST /{app}/{Handler.Method} is forwarded to the app and the response is
+// written back as JSON. The Authorization header and any application headers
+// allowed are passed on as metadata.
+//
+//	gw := gateway.New(rpc.NewClient(), gateway.Headers("Accept-Language"))
+//	http.ListenAndServe(":8080", gw)
+//
+// A streaming endpoint is called when the request accepts text/event-stream,
+// which writes each message as a server-sent event, or application/x-ndjson,
+// which writes each message as a line of a chunked response.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/router"
+)
+
+var (
+	// DefaultMaxBodySize is the default size limit of a request body in bytes
+	DefaultMaxBodySize int64 = 4 << 20
+	// RetryAfter is the time set in the Retry-After header when an app is
+	// unavailable and the app didn't say how long to wait
+	RetryAfter = time.Second
+
+	// EventStream is the content type of a stream of server-sent events
+	EventStream = "text/event-stream"
+	// NDJSON is the content type of a stream of newline delimited JSON
+	NDJSON = "application/x-ndjson"
+
+	// DefaultHeaders are the request headers passed on as metadata
+	DefaultHeaders = []string{"Authorization"}
+
+	// protocol headers which are never passed on as metadata as
+	// they'd change how the request is framed, routed or cancelled
+	protocolHeaders = map[string]bool{
+		"Accept":       true,
+		"App":          true,
+		"Cache-Bypass": true,
+		"Content-Type": true,
+		"Control":      true,
+		"Endpoint":     true,
+		"Error":        true,
+		"Event":        true,
+		"Hedge":        true,
+		"Id":           true,
+		"Local":        true,
+		"Method":       true,
+		"Protocol":     true,
+		"Remote":       true,
+		"Retry-After":  true,
+		"Stream":       true,
+		"Target":       true,
+		"Timeout":      true,
+	}
+)
+
+type Options struct {
+	// Router used to resolve apps, defaults to the router of the client
+	Router router.Router
+	// MaxBodySize is the size limit of a request body in bytes
+	MaxBodySize int64
+	// Headers are the request headers passed on as metadata
+	Headers []string
+}
+
+type Option func(*Options)
+
+// Router sets the router used to resolve apps
+func Router(r router.Router) Option {
+	return func(o *Options) {
+		o.Router = r
+	}
+}
+
+// MaxBodySize sets the size limit of a request body in bytes
+func MaxBodySize(n int64) Option {
+	return func(o *Options) {
+		o.MaxBodySize = n
+	}
+}
+
+// Headers adds application headers to the request headers passed on as metadata.
+// Protocol headers such as Id, Stream or Control are never passed on.
+func Headers(h ...string) Option {
+	return func(o *Options) {
+		o.Headers = append(o.Headers, h...)
+	}
+}
+
+// Gateway is a http.Handler which forwards requests to apps using a client
+type Gateway struct {
+	opts   Options
+	client client.Client
+}
+
+// New returns a Gateway which forwards requests using the client
+func New(c client.Client, opts ...Option) *Gateway {
+	options := Options{
+		MaxBodySize: DefaultMaxBodySize,
+		Headers:     append([]string(nil), DefaultHeaders...),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Router == nil {
+		options.Router = c.Options().Router
+	}
+
+	return &Gateway{
+		opts:   options,
+		client: c,
+	}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, errors.MethodNotAllowed("nitro", "method %s not allowed", r.Method))
+		return
+	}
+
+	app, endpoint, ok := parsePath(r.URL.Path)
+	if !ok {
+		writeError(w, errors.NotFound("nitro", "no endpoint at %s", r.URL.Path))
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, g.opts.MaxBodySize))
+	if err != nil {
+		writeError(w, errors.BadRequest("nitro", "error reading request body: %v", err))
+		return
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}")
+	}
+	if !json.Valid(body) {
+		writeError(w, errors.BadRequest("nitro", "request body is not valid JSON"))
+		return
+	}
+
+	// resolve the app so an unknown app is reported as not found
+	if _, err := g.opts.Router.Lookup(app); err == router.ErrRouteNotFound {
+		writeError(w, errors.NotFound("nitro", "app %s not found", app))
+		return
+	} else if err != nil {
+		writeError(w, errors.BadGateway("nitro", "error resolving app %s: %v", app, err))
+		return
+	}
+
+	ctx := metadata.NewContext(r.Context(), headerMetadata(r.Header, g.opts.Headers))
+	opts := []client.CallOption{client.WithRouter(g.opts.Router)}
+
+	accept := r.Header.Get("Accept")
+
+	switch {
+	case strings.Contains(accept, EventStream):
+		g.stream(ctx, w, app, endpoint, body, EventStream, opts)
+	case strings.Contains(accept, NDJSON):
+		g.stream(ctx, w, app, endpoint, body, NDJSON, opts)
+	default:
+		g.call(ctx, w, app, endpoint, body, opts)
+	}
+}
+
+// call the endpoint and write the response
+func (g *Gateway) call(ctx context.Context, w http.ResponseWriter, app, endpoint string, body []byte, opts []client.CallOption) {
+	req := g.client.NewRequest(app, endpoint, json.RawMessage(body), client.WithContentType("application/json"))
+
+	// the time the app asks to wait before retrying
+	var wait time.Duration
+	opts = append(opts, client.WithRetryAfter(&wait))
+
+	var rsp json.RawMessage
+	if err := g.client.Call(ctx, req, &rsp, opts...); err != nil {
+		if wait == 0 {
+			wait = RetryAfter
+		}
+		writeRetryError(w, err, wait)
+		return
+	}
+
+	if len(rsp) == 0 {
+		rsp = json.RawMessage("{}")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rsp)
+}
+
+// stream the messages of the endpoint in the content type accepted. The body
+// is sent as the first message and every message received is flushed.
+func (g *Gateway) stream(ctx context.Context, w http.ResponseWriter, app, endpoint string, body []byte, contentType string, opts []client.CallOption) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, errors.InternalServerError("nitro", "streaming is not supported"))
+		return
+	}
+
+	req := g.client.NewRequest(app, endpoint, json.RawMessage(body), client.WithContentType("application/json"), client.StreamingRequest())
+
+	stream, err := g.client.Stream(ctx, req, opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer stream.Close()
+
+	if err := stream.Send(json.RawMessage(body)); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var started bool
+
+	for {
+		var msg json.RawMessage
+
+		err := stream.Recv(&msg)
+		if err == io.EOF {
+			if !started {
+				w.Header().Set("Content-Type", contentType)
+				w.WriteHeader(http.StatusOK)
+			}
+			return
+		}
+
+		// errors before the first message are written as the response
+		if err != nil && !started {
+			writeError(w, err)
+			return
+		}
+
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+		}
+
+		if err != nil {
+			writeMessage(w, contentType, "error", errorBody(err))
+			flusher.Flush()
+			return
+		}
+
+		writeMessage(w, contentType, "", msg)
+		flusher.Flush()
+	}
+}
+
+// writeMessage writes a message of a stream. For server-sent events the event
+// name is set when not empty, newline delimited JSON wraps errors in an object.
+func writeMessage(w io.Writer, contentType, event string, msg []byte) {
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, msg); err != nil {
+		buf.Reset()
+		buf.Write(msg)
+	}
+
+	if contentType == EventStream {
+		if len(event) > 0 {
+			fmt.Fprintf(w, "event: %s\n", event)
+		}
+		fmt.Fprintf(w, "data: %s\n\n", buf.Bytes())
+		return
+	}
+
+	if len(event) > 0 {
+		fmt.Fprintf(w, "{%q:%s}\n", event, buf.Bytes())
+		return
+	}
+
+	fmt.Fprintf(w, "%s\n", buf.Bytes())
+}
+
+// writeError writes the error as JSON with the status of its code
+func writeError(w http.ResponseWriter, err error) {
+	writeRetryError(w, err, RetryAfter)
+}
+
+// writeRetryError writes the error as JSON with the status of its code. If the
+// app is unavailable the Retry-After is set to wait rounded up to seconds.
+func writeRetryError(w http.ResponseWriter, err error, wait time.Duration) {
+	code := statusCode(err)
+
+	if code == http.StatusServiceUnavailable || code == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(errorBody(err))
+}
+
+// statusCode returns the HTTP status of the error code, errors
+// without a valid code are internal server errors
+func statusCode(err error) int {
+	code := int(errors.FromError(err).Code)
+	if code < 400 || code > 599 {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+// errorBody returns the error encoded as an errors.Error
+func errorBody(err error) []byte {
+	e := errors.FromError(err)
+
+	code := statusCode(err)
+	if len(e.Status) == 0 {
+		e = &errors.Error{Id: e.Id, Code: int32(code), Detail: e.Detail, Status: http.StatusText(code)}
+	}
+
+	b, _ := json.Marshal(e)
+	return b
+}
+
+// parsePath returns the app and endpoint of a path in the form /{app}/{Handler.Method}
+func parsePath(path string) (string, string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return "", "", false
+	}
+
+	if i := strings.Index(parts[1], "."); i < 1 || i == len(parts[1])-1 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// headerMetadata returns the allowed request headers as metadata
+func headerMetadata(hdr http.Header, allowed []string) metadata.Metadata {
+	md := make(metadata.Metadata, len(allowed))
+
+	for _, k := range allowed {
+		k = http.CanonicalHeaderKey(k)
+
+		// the server also reads protocol headers prefixed with X-
+		if protocolHeaders[k] || protocolHeaders[strings.TrimPrefix(k, "X-")] {
+			continue
+		}
+
+		if v, ok := hdr[k]; ok {
+			md[k] = strings.Join(v, ",")
+		}
+	}
+
+	return md
+}
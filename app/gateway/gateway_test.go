@@ -0,0 +1,327 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/client/rpc"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/registry/memory"
+	"github.com/gonitro/nitro/app/router"
+	regRouter "github.com/gonitro/nitro/app/router/registry"
+	"github.com/gonitro/nitro/app/server"
+	rpcServer "github.com/gonitro/nitro/app/server/rpc"
+)
+
+type Request struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type Response struct {
+	Greeting string `json:"greeting"`
+}
+
+type Greeter struct{}
+
+func (g *Greeter) Hello(ctx context.Context, req *Request, rsp *Response) error {
+	if len(req.Name) == 0 {
+		return errors.BadRequest("greeter", "name is required")
+	}
+	prefix, _ := metadata.Get(ctx, "Greeting")
+	rsp.Greeting = prefix + " " + req.Name
+	return nil
+}
+
+func (g *Greeter) Count(ctx context.Context, stream server.Stream) error {
+	req := new(Request)
+	if err := stream.Recv(req); err != nil {
+		return err
+	}
+	for i := 0; i < req.Count; i++ {
+		if err := stream.Send(&Response{Greeting: req.Name}); err != nil {
+			return err
+		}
+	}
+	if req.Count == 0 {
+		return errors.BadRequest("greeter", "count is required")
+	}
+	return nil
+}
+
+func testGateway(t *testing.T) (*httptest.Server, func()) {
+	reg := memory.NewTable()
+
+	s := rpcServer.NewServer(
+		server.Name("greeter"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(reg),
+		server.Transport(socket.NewTransport()),
+	)
+	if err := s.Handle(s.NewHandler(new(Greeter))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpc.NewClient(
+		client.Router(regRouter.NewRouter(router.Registry(reg))),
+		client.Transport(socket.NewTransport()),
+	)
+
+	ts := httptest.NewServer(New(c, Headers("Greeting")))
+
+	return ts, func() {
+		ts.Close()
+		s.Stop()
+	}
+}
+
+func post(t *testing.T, url, accept, body string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Greeting", "Hello")
+	if len(accept) > 0 {
+		req.Header.Set("Accept", accept)
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rsp, string(b)
+}
+
+func TestGatewayCall(t *testing.T) {
+	ts, stop := testGateway(t)
+	defer stop()
+
+	rsp, body := post(t, ts.URL+"/greeter/Greeter.Hello", "", `{"name": "John"}`)
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 got %d: %s", rsp.StatusCode, body)
+	}
+	if ct := rsp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected application/json got %s", ct)
+	}
+
+	var greeting Response
+	if err := json.Unmarshal([]byte(body), &greeting); err != nil {
+		t.Fatal(err)
+	}
+	if greeting.Greeting != "Hello John" {
+		t.Fatalf("Expected the header to be passed as metadata got %q", greeting.Greeting)
+	}
+
+	testCases := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodPost, "/greeter/Greeter.Hello", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/greeter/Greeter.Hello", `{"name":`, http.StatusBadRequest},
+		{http.MethodPost, "/missing/Greeter.Hello", `{}`, http.StatusNotFound},
+		{http.MethodPost, "/greeter", `{}`, http.StatusNotFound},
+		{http.MethodGet, "/greeter/Greeter.Hello", ``, http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		req, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if rsp.StatusCode != tc.code {
+			t.Fatalf("%s %s: expected %d got %d: %s", tc.method, tc.path, tc.code, rsp.StatusCode, b)
+		}
+		if e := errors.Parse(string(b)); e.Code != int32(tc.code) {
+			t.Fatalf("%s %s: expected error with code %d got %s", tc.method, tc.path, tc.code, b)
+		}
+	}
+}
+
+func TestGatewayProtocolHeaders(t *testing.T) {
+	ts, stop := testGateway(t)
+	defer stop()
+
+	// a cancel frame would leave the request waiting for its timeout
+	for _, hdr := range []string{"Control", "X-Control"} {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/greeter/Greeter.Hello", strings.NewReader(`{"name": "John"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(hdr, "cancel")
+		req.Header.Set("Stream", "true")
+		req.Header.Set("Id", "1")
+
+		client := &http.Client{Timeout: time.Second}
+
+		rsp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200 got %d: %s", hdr, rsp.StatusCode, b)
+		}
+	}
+
+	hdr := http.Header{}
+	hdr.Set("Authorization", "Bearer token")
+	hdr.Set("Greeting", "Hello")
+	hdr.Set("Control", "cancel")
+	hdr.Set("X-Id", "1")
+	hdr.Set("Other", "value")
+
+	// protocol headers are dropped even when allowed
+	md := headerMetadata(hdr, append(DefaultHeaders, "Greeting", "Control", "X-Id"))
+	if len(md) != 2 || md["Authorization"] != "Bearer token" || md["Greeting"] != "Hello" {
+		t.Fatalf("Expected Authorization and Greeting metadata got %v", md)
+	}
+}
+
+func TestGatewayStream(t *testing.T) {
+	ts, stop := testGateway(t)
+	defer stop()
+
+	rsp, body := post(t, ts.URL+"/greeter/Greeter.Count", EventStream, `{"name": "John", "count": 2}`)
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 got %d: %s", rsp.StatusCode, body)
+	}
+	if ct := rsp.Header.Get("Content-Type"); ct != EventStream {
+		t.Fatalf("Expected %s got %s", EventStream, ct)
+	}
+	expect := "data: {\"greeting\":\"John\"}\n\ndata: {\"greeting\":\"John\"}\n\n"
+	if body != expect {
+		t.Fatalf("Expected %q got %q", expect, body)
+	}
+
+	rsp, body = post(t, ts.URL+"/greeter/Greeter.Count", NDJSON, `{"name": "John", "count": 2}`)
+	if ct := rsp.Header.Get("Content-Type"); ct != NDJSON {
+		t.Fatalf("Expected %s got %s", NDJSON, ct)
+	}
+	expect = "{\"greeting\":\"John\"}\n{\"greeting\":\"John\"}\n"
+	if body != expect {
+		t.Fatalf("Expected %q got %q", expect, body)
+	}
+
+	// an error before the first message is written as the response
+	rsp, body = post(t, ts.URL+"/greeter/Greeter.Count", EventStream, `{"name": "John"}`)
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 got %d: %s", rsp.StatusCode, body)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	testCases := []struct {
+		err  error
+		code int
+	}{
+		{errors.AppUnavailable("greeter", "busy"), http.StatusServiceUnavailable},
+		{errors.Forbidden("greeter", "forbidden"), http.StatusForbidden},
+		{errors.New("greeter", "unknown", 0), http.StatusInternalServerError},
+		{errors.New("greeter", "redirect", 302), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		if code := statusCode(tc.err); code != tc.code {
+			t.Fatalf("%v: expected %d got %d", tc.err, tc.code, code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	writeError(w, errors.AppUnavailable("greeter", "busy"))
+	if w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Expected Retry-After 1 got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+// Block holds the requests it serves until released
+type Block struct {
+	started chan bool
+	release chan bool
+}
+
+func (b *Block) Hello(ctx context.Context, req *Request, rsp *Response) error {
+	b.started <- true
+	<-b.release
+	return nil
+}
+
+func TestRetryAfter(t *testing.T) {
+	reg := memory.NewTable()
+	b := &Block{started: make(chan bool, 1), release: make(chan bool)}
+
+	// the server sheds requests over its limit
+	s := rpcServer.NewServer(
+		server.Name("block"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(reg),
+		server.Transport(socket.NewTransport()),
+		server.MaxConcurrency(1),
+		server.RetryAfter(time.Millisecond*2500),
+	)
+	if err := s.Handle(s.NewHandler(b)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	c := rpc.NewClient(
+		client.Router(regRouter.NewRouter(router.Registry(reg))),
+		client.Transport(socket.NewTransport()),
+		client.Retries(0),
+	)
+
+	ts := httptest.NewServer(New(c))
+	defer ts.Close()
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		post(t, ts.URL+"/block/Block.Hello", "", `{"name": "John"}`)
+	}()
+	<-b.started
+
+	// the app's Retry-After is passed on rounded up to seconds
+	rsp, body := post(t, ts.URL+"/block/Block.Hello", "", `{"name": "Jane"}`)
+	close(b.release)
+	<-done
+
+	if rsp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 got %d: %s", rsp.StatusCode, body)
+	}
+	if v := rsp.Header.Get("Retry-After"); v != "3" {
+		t.Fatalf("Expected Retry-After 3 got %q", v)
+	}
+}
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/server"
+)
+
+// Request to check the health of the app
+type Request struct {
+	// Checks to run, if blank all checks are run
+	Checks []string `json:"checks"`
+}
+
+// Response with the health of the app
+type Response struct {
+	// Status is Serving or NotServing
+	Status string `json:"status"`
+	// Checks is the result of each check
+	Checks map[string]string `json:"checks"`
+}
+
+// Health is the internal handler used to query the health of an app.
+// It's registered as an internal handler so it's not advertised.
+type Health struct {
+	checker *Checker
+}
+
+// NewHandler returns a new Health handler for the checker
+func NewHandler(c *Checker) *Health {
+	return &Health{checker: c}
+}
+
+// Check runs the checks and returns the status. The tracked
+// status, and so the one advertised, is left unchanged.
+func (h *Health) Check(ctx context.Context, req *Request, rsp *Response) error {
+	status := h.checker.Check(ctx, req.Checks...)
+	rsp.Status = status.Status
+	rsp.Checks = status.Checks
+	return nil
+}
+
+// Ready returns the last known status or an error if the app is not serving
+func (h *Health) Ready(ctx context.Context, req *Request, rsp *Response) error {
+	status := h.checker.Status()
+	rsp.Status = status.Status
+	rsp.Checks = status.Checks
+
+	if status.Status != Serving {
+		return errors.AppUnavailable("health", "app is %s", status.Status)
+	}
+
+	return nil
+}
+
+// Live returns serving if the app is able to process requests
+func (h *Health) Live(ctx context.Context, req *Request, rsp *Response) error {
+	rsp.Status = Serving
+	return nil
+}
+
+// Watch streams the current status followed by every change in status
+func (h *Health) Watch(ctx context.Context, stream server.Stream) error {
+	updates, stop := h.checker.Watch()
+	defer stop()
+
+	// detect the client closing the stream
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for {
+			var req Request
+			if err := stream.Recv(&req); err != nil {
+				return
+			}
+		}
+	}()
+
+	status := h.checker.Status()
+
+	for {
+		if err := stream.Send(&Response{
+			Status: status.Status,
+			Checks: status.Checks,
+		}); err != nil {
+			return err
+		}
+
+		select {
+		case status = <-updates:
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package health provides health, readiness and liveness checking for an app
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/event"
+	"github.com/gonitro/nitro/app/registry"
+	"github.com/gonitro/nitro/db"
+	"github.com/gonitro/nitro/util/uuid"
+)
+
+const (
+	// Serving indicates all checks passed
+	Serving = "serving"
+	// NotServing indicates one or more checks failed
+	NotServing = "not_serving"
+	// MetadataKey is the registry instance metadata key the status is advertised under
+	MetadataKey = "health"
+)
+
+var (
+	// DefaultInterval is the interval on which checks are run
+	DefaultInterval = time.Second * 10
+	// DefaultTimeout is the max time a single run of the checks can take
+	DefaultTimeout = time.Second * 5
+	// DefaultEvent is the event published to by the BrokerPublish check
+	DefaultEvent = "nitro.health"
+)
+
+// CheckFunc is a health check. A nil error indicates the check passed.
+type CheckFunc func(context.Context) error
+
+// Status is the aggregated result of running the checks
+type Status struct {
+	// Status is Serving or NotServing
+	Status string `json:"status"`
+	// Checks is the result of each check, "ok" or the error
+	Checks map[string]string `json:"checks"`
+	// Timestamp of when the checks were run
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Checker runs a set of named checks and tracks the aggregated status
+type Checker struct {
+	sync.RWMutex
+	// held for the duration of a run
+	running  sync.Mutex
+	checks   map[string]CheckFunc
+	status   *Status
+	watchers map[string]chan *Status
+	exit     chan bool
+}
+
+// NewChecker returns a new checker with no checks
+func NewChecker() *Checker {
+	return &Checker{
+		checks:   make(map[string]CheckFunc),
+		watchers: make(map[string]chan *Status),
+	}
+}
+
+// Add a named check, replacing any existing check with the same name
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.Lock()
+	c.checks[name] = fn
+	c.Unlock()
+}
+
+// Remove a named check
+func (c *Checker) Remove(name string) {
+	c.Lock()
+	delete(c.checks, name)
+	c.Unlock()
+}
+
+// Run executes all the checks and updates the tracked status. Runs are
+// executed one at a time so a slow run can't overwrite a newer status.
+func (c *Checker) Run(ctx context.Context) *Status {
+	c.running.Lock()
+	defer c.running.Unlock()
+
+	status := c.Check(ctx)
+	c.update(status)
+	return status
+}
+
+// Check executes the checks and returns the status without updating the
+// tracked status. If names are provided only those checks are run.
+func (c *Checker) Check(ctx context.Context, names ...string) *Status {
+	c.RLock()
+	checks := make(map[string]CheckFunc, len(c.checks))
+	for name, fn := range c.checks {
+		checks[name] = fn
+	}
+	c.RUnlock()
+
+	// filter to the requested checks
+	if len(names) > 0 {
+		filtered := make(map[string]CheckFunc, len(names))
+		for _, name := range names {
+			if fn, ok := checks[name]; ok {
+				filtered[name] = fn
+			}
+		}
+		checks = filtered
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	status := &Status{
+		Status:    Serving,
+		Checks:    make(map[string]string, len(checks)),
+		Timestamp: time.Now(),
+	}
+
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, fn := range checks {
+		wg.Add(1)
+
+		go func(name string, fn CheckFunc) {
+			defer wg.Done()
+
+			res := "ok"
+			if err := fn(ctx); err != nil {
+				res = err.Error()
+			}
+
+			mtx.Lock()
+			status.Checks[name] = res
+			if res != "ok" {
+				status.Status = NotServing
+			}
+			mtx.Unlock()
+		}(name, fn)
+	}
+
+	wg.Wait()
+
+	return status
+}
+
+// update sets the tracked status and notifies watchers on change
+func (c *Checker) update(status *Status) {
+	c.Lock()
+	defer c.Unlock()
+
+	changed := c.status == nil || c.status.Status != status.Status
+	c.status = status
+
+	if !changed {
+		return
+	}
+
+	for _, ch := range c.watchers {
+		// replace any status the watcher has not yet consumed
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- status
+	}
+}
+
+// Status returns the last tracked status. If the checks
+// have never been run they will be run now.
+func (c *Checker) Status() *Status {
+	c.RLock()
+	status := c.status
+	c.RUnlock()
+
+	if status != nil {
+		return status
+	}
+
+	return c.Run(context.Background())
+}
+
+// Watch returns a channel of status changes and a func to stop watching
+func (c *Checker) Watch() (<-chan *Status, func()) {
+	id := uuid.New().String()
+	ch := make(chan *Status, 1)
+
+	c.Lock()
+	c.watchers[id] = ch
+	c.Unlock()
+
+	return ch, func() {
+		c.Lock()
+		delete(c.watchers, id)
+		c.Unlock()
+	}
+}
+
+// Start runs the checks on the given interval until Stop is called
+func (c *Checker) Start(interval time.Duration) {
+	c.Lock()
+	if c.exit != nil {
+		c.Unlock()
+		return
+	}
+	exit := make(chan bool)
+	c.exit = exit
+	c.Unlock()
+
+	if interval <= time.Duration(0) {
+		interval = DefaultInterval
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		// run the checks right away
+		c.Run(context.Background())
+
+		for {
+			select {
+			case <-t.C:
+				c.Run(context.Background())
+			case <-exit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop the interval checks
+func (c *Checker) Stop() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.exit == nil {
+		return
+	}
+
+	close(c.exit)
+	c.exit = nil
+}
+
+// Broker checks the broker is connected. Only brokers which report their
+// connection state with a Connected() bool method are checked, use
+// BrokerPublish for those which don't.
+func Broker(b event.Broker) CheckFunc {
+	return func(ctx context.Context) error {
+		c, ok := b.(interface{ Connected() bool })
+		if !ok || c.Connected() {
+			return nil
+		}
+		return fmt.Errorf("broker %s not connected", b.String())
+	}
+}
+
+// BrokerPublish checks the broker is connected by publishing to the DefaultEvent.
+// Every subscriber of the event receives a message on each run of the checks.
+func BrokerPublish(b event.Broker) CheckFunc {
+	return func(ctx context.Context) error {
+		return b.Publish(DefaultEvent, &event.Message{
+			Header: map[string]string{"Event": DefaultEvent},
+		}, event.PublishContext(ctx))
+	}
+}
+
+// Registry checks the registry is reachable by listing apps
+func Registry(r registry.Table) CheckFunc {
+	return func(ctx context.Context) error {
+		_, err := r.List(registry.ListContext(ctx))
+		return err
+	}
+}
+
+// Store checks the db store is open by listing a single key
+func Store(s db.Store) CheckFunc {
+	return func(ctx context.Context) error {
+		_, err := s.List(db.ListLimit(1))
+		return err
+	}
+}
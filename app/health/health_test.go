@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/event"
+	mevent "github.com/gonitro/nitro/app/event/memory"
+	"github.com/gonitro/nitro/app/registry/memory"
+)
+
+func TestChecker(t *testing.T) {
+	c := NewChecker()
+	c.Add("registry", Registry(memory.NewTable()))
+
+	var fail int32
+	c.Add("custom", func(ctx context.Context) error {
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("custom failure")
+		}
+		return nil
+	})
+
+	status := c.Run(context.Background())
+	if status.Status != Serving {
+		t.Fatalf("Expected status %s got %s", Serving, status.Status)
+	}
+	if len(status.Checks) != 2 {
+		t.Fatalf("Expected 2 checks got %d", len(status.Checks))
+	}
+
+	updates, stop := c.Watch()
+	defer stop()
+
+	atomic.StoreInt32(&fail, 1)
+
+	status = c.Run(context.Background())
+	if status.Status != NotServing {
+		t.Fatalf("Expected status %s got %s", NotServing, status.Status)
+	}
+	if status.Checks["custom"] != "custom failure" {
+		t.Fatalf("Expected custom check error got %q", status.Checks["custom"])
+	}
+	if status.Checks["registry"] != "ok" {
+		t.Fatalf("Expected registry check ok got %q", status.Checks["registry"])
+	}
+
+	select {
+	case update := <-updates:
+		if update.Status != NotServing {
+			t.Fatalf("Expected update %s got %s", NotServing, update.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected status update")
+	}
+
+	// checking a subset of checks does not change the tracked status
+	if status := c.Check(context.Background(), "registry"); status.Status != Serving {
+		t.Fatalf("Expected status %s got %s", Serving, status.Status)
+	}
+	if status := c.Status(); status.Status != NotServing {
+		t.Fatalf("Expected tracked status %s got %s", NotServing, status.Status)
+	}
+}
+
+func TestCheckerRunOrder(t *testing.T) {
+	c := NewChecker()
+
+	var fail int32
+	started := make(chan bool, 2)
+	release := make(chan bool)
+
+	c.Add("custom", func(ctx context.Context) error {
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("custom failure")
+		}
+		// the first run is slow and passes
+		started <- true
+		<-release
+		return nil
+	})
+
+	done := make(chan bool, 2)
+	go func() {
+		c.Run(context.Background())
+		done <- true
+	}()
+	<-started
+
+	// a newer run fails while the first is in flight
+	atomic.StoreInt32(&fail, 1)
+	go func() {
+		c.Run(context.Background())
+		done <- true
+	}()
+
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	<-done
+	<-done
+
+	if status := c.Status(); status.Status != NotServing {
+		t.Fatalf("Expected tracked status %s got %s", NotServing, status.Status)
+	}
+}
+
+func TestBroker(t *testing.T) {
+	b := mevent.NewBroker()
+
+	check := Broker(b)
+	if err := check(context.Background()); err == nil {
+		t.Fatal("Expected disconnected broker to fail")
+	}
+
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	var events int32
+	if _, err := b.Subscribe(DefaultEvent, func(*event.Message) error {
+		atomic.AddInt32(&events, 1)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the check doesn't publish any events
+	if n := atomic.LoadInt32(&events); n != 0 {
+		t.Fatalf("Expected no events got %d", n)
+	}
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gonitro/nitro/app/logger"
+)
+
+var (
+	// DefaultHookTimeout is the max time a hook can take to start or stop
+	DefaultHookTimeout = time.Second * 30
+)
+
+// Hook is a named component of the program with a lifecycle. Hooks are started
+// in order before the server starts and stopped in reverse order after the server
+// stops. If startup fails partway the hooks already started are stopped.
+type Hook struct {
+	// Name of the hook used in logs and errors
+	Name string
+	// Start the component. Optional.
+	Start func(context.Context) error
+	// Stop the component. Optional.
+	Stop func(context.Context) error
+	// Timeout for each of Start and Stop. Defaults to DefaultHookTimeout.
+	Timeout time.Duration
+}
+
+// run calls fn with a context which is cancelled after the hook timeout.
+// An error is returned on timeout even if fn ignores the context.
+func (h Hook) run(ctx context.Context, action string, fn func(context.Context) error) error {
+	if fn == nil {
+		return nil
+	}
+
+	timeout := h.Timeout
+	if timeout <= time.Duration(0) {
+		timeout = DefaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+		logger.Debugf("Hook [%s] %s", h.Name, action)
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic recovered: %v", r)
+			}
+		}()
+		errCh <- fn(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("hook %s %s: %v", h.Name, action, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("hook %s %s: %v", h.Name, action, ctx.Err())
+	}
+}
+
+// startHooks starts the hooks in order. If a hook fails the hooks
+// already started are stopped in reverse order and the error returned.
+func startHooks(ctx context.Context, hooks []Hook) ([]Hook, error) {
+	started := make([]Hook, 0, len(hooks))
+
+	for _, h := range hooks {
+		if err := h.run(ctx, "start", h.Start); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error starting %v, rolling back %d hooks", err, len(started))
+			}
+			stopHooks(ctx, started)
+			return nil, err
+		}
+		started = append(started, h)
+	}
+
+	return started, nil
+}
+
+// stopHooks stops the hooks in reverse order and returns every error
+func stopHooks(ctx context.Context, hooks []Hook) []error {
+	var errs []error
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].run(ctx, "stop", hooks[i].Stop); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error stopping %v", err)
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// lifecycle returns the hooks of the options in the order they're started. The
+// BeforeStart and AfterStop funcs are the first hook so if startup fails after
+// they ran the AfterStop funcs clean up. The BeforeStop funcs are the last hook
+// so they run first once the server is stopped.
+func lifecycle(o Options) []Hook {
+	hooks := make([]Hook, 0, len(o.Hooks)+2)
+
+	if len(o.BeforeStart) > 0 || len(o.AfterStop) > 0 {
+		hooks = append(hooks, Hook{
+			Name:  "before start/after stop",
+			Start: runFuncs(o.BeforeStart, false),
+			Stop:  runFuncs(o.AfterStop, true),
+		})
+	}
+
+	hooks = append(hooks, o.Hooks...)
+
+	if len(o.BeforeStop) > 0 {
+		hooks = append(hooks, Hook{
+			Name: "before stop",
+			Stop: runFuncs(o.BeforeStop, true),
+		})
+	}
+
+	return hooks
+}
+
+// runFuncs returns a hook func which calls the funcs in order. If all is
+// set every func is called and the errors joined, otherwise the first
+// error is returned.
+func runFuncs(fns []func() error, all bool) func(context.Context) error {
+	if len(fns) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context) error {
+		var errs []string
+
+		for _, fn := range fns {
+			if err := fn(); err != nil {
+				if !all {
+					return err
+				}
+				errs = append(errs, err.Error())
+			}
+		}
+
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
+
+		return nil
+	}
+}
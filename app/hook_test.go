@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testHooks struct {
+	sync.Mutex
+	events []string
+}
+
+func (t *testHooks) hook(name string, err error) Hook {
+	record := func(event string) {
+		t.Lock()
+		t.events = append(t.events, event)
+		t.Unlock()
+	}
+
+	return Hook{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("no deadline")
+			}
+			if err != nil {
+				return err
+			}
+			record("start " + name)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	th := new(testHooks)
+
+	prog := New(
+		Name("test.hooks"),
+		Address("127.0.0.1:0"),
+		Hooks(th.hook("db", nil), th.hook("cache", nil)),
+		Hooks(th.hook("queue", nil)),
+	)
+
+	if err := prog.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := prog.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"start db", "start cache", "start queue", "stop queue", "stop cache", "stop db"}
+	if !reflect.DeepEqual(th.events, expect) {
+		t.Fatalf("Expected %v got %v", expect, th.events)
+	}
+}
+
+func TestHooksRollback(t *testing.T) {
+	th := new(testHooks)
+
+	prog := New(
+		Name("test.hooks.rollback"),
+		Address("127.0.0.1:0"),
+		Hooks(th.hook("db", nil), th.hook("cache", nil), th.hook("queue", errors.New("unavailable"))),
+	)
+
+	err := prog.Start()
+	if err == nil || !strings.Contains(err.Error(), "queue") {
+		t.Fatalf("Expected error naming the queue hook got %v", err)
+	}
+
+	expect := []string{"start db", "start cache", "stop cache", "stop db"}
+	if !reflect.DeepEqual(th.events, expect) {
+		t.Fatalf("Expected %v got %v", expect, th.events)
+	}
+
+	// the server was never started
+	if prog.Server().Options().Address != "127.0.0.1:0" {
+		t.Fatalf("Expected server not to be started")
+	}
+}
+
+// fn records the event and returns err
+func (t *testHooks) fn(event string, err error) func() error {
+	return func() error {
+		t.Lock()
+		t.events = append(t.events, event)
+		t.Unlock()
+		return err
+	}
+}
+
+func TestHooksBeforeAfter(t *testing.T) {
+	testData := []struct {
+		name   string
+		hook   error
+		start  error
+		expect []string
+	}{
+		{
+			name:   "test.hooks.stop",
+			expect: []string{"before start", "start db", "after start", "before stop", "stop db", "after stop"},
+		},
+		// the before start funcs are cleaned up when a hook fails
+		{
+			name:   "test.hooks.hook",
+			hook:   errors.New("unavailable"),
+			expect: []string{"before start", "after stop"},
+		},
+		// everything started is stopped when an after start func fails
+		{
+			name:   "test.hooks.start",
+			start:  errors.New("failed"),
+			expect: []string{"before start", "start db", "after start", "before stop", "stop db", "after stop"},
+		},
+	}
+
+	for _, d := range testData {
+		th := new(testHooks)
+
+		prog := New(
+			Name(d.name),
+			Address("127.0.0.1:0"),
+			BeforeStart(th.fn("before start", nil)),
+			AfterStart(th.fn("after start", d.start)),
+			BeforeStop(th.fn("before stop", nil)),
+			AfterStop(th.fn("after stop", nil)),
+			Hooks(th.hook("db", d.hook)),
+		)
+
+		err := prog.Start()
+		if (err != nil) != (d.hook != nil || d.start != nil) {
+			t.Fatalf("%s: unexpected start error %v", d.name, err)
+		}
+		if err == nil {
+			if err := prog.Stop(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if !reflect.DeepEqual(th.events, d.expect) {
+			t.Fatalf("%s: expected %v got %v", d.name, d.expect, th.events)
+		}
+	}
+}
+
+func TestHooksTimeout(t *testing.T) {
+	th := new(testHooks)
+	block := make(chan bool)
+	defer close(block)
+
+	prog := New(
+		Name("test.hooks.timeout"),
+		Address("127.0.0.1:0"),
+		Hooks(th.hook("db", nil), Hook{
+			Name: "slow",
+			// ignores the context
+			Start: func(ctx context.Context) error {
+				<-block
+				return nil
+			},
+			Timeout: time.Millisecond * 10,
+		}),
+	)
+
+	err := prog.Start()
+	if err == nil || !strings.Contains(err.Error(), "slow") {
+		t.Fatalf("Expected timeout error naming the slow hook got %v", err)
+	}
+
+	expect := []string{"start db", "stop db"}
+	if !reflect.DeepEqual(th.events, expect) {
+		t.Fatalf("Expected %v got %v", expect, th.events)
+	}
+}
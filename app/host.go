@@ -0,0 +1,341 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/event"
+	mevent "github.com/gonitro/nitro/app/event/memory"
+	"github.com/gonitro/nitro/app/logger"
+	sock "github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/registry/memory"
+	"github.com/gonitro/nitro/app/router"
+	regRouter "github.com/gonitro/nitro/app/router/registry"
+)
+
+var (
+	// DefaultHostAddress is the address programs in a host listen on
+	DefaultHostAddress = "127.0.0.1:0"
+)
+
+// Host runs many programs in a single process. The programs share the
+// same broker, registry and transport so they can find and call each other.
+type Host struct {
+	sync.RWMutex
+	opts HostOptions
+
+	// programs keyed by name
+	programs map[string]*nitroProgram
+	started  bool
+}
+
+// hostBroker is the broker shared by the programs in a host. It stays
+// connected until the host and every program have disconnected.
+type hostBroker struct {
+	event.Broker
+
+	sync.Mutex
+	refs int
+}
+
+func (b *hostBroker) Connect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.refs == 0 {
+		if err := b.Broker.Connect(); err != nil {
+			return err
+		}
+	}
+
+	b.refs++
+	return nil
+}
+
+func (b *hostBroker) Disconnect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.refs == 0 {
+		return nil
+	}
+
+	b.refs--
+	if b.refs > 0 {
+		return nil
+	}
+
+	return b.Broker.Disconnect()
+}
+
+// Connected returns true if the shared broker is connected
+func (b *hostBroker) Connected() bool {
+	c, ok := b.Broker.(interface{ Connected() bool })
+	return !ok || c.Connected()
+}
+
+// clientBroker is the shared broker used by the client of a program. The
+// client connects to publish but never disconnects so it holds no reference.
+type clientBroker struct {
+	*hostBroker
+}
+
+func (b *clientBroker) Connect() error {
+	b.Lock()
+	defer b.Unlock()
+
+	// already connected by the host or a program
+	if b.refs > 0 {
+		return nil
+	}
+
+	return b.Broker.Connect()
+}
+
+func (b *clientBroker) Disconnect() error {
+	return nil
+}
+
+// NewHost returns a host with its own broker, registry and transport
+func NewHost(opts ...HostOption) *Host {
+	options := HostOptions{
+		Broker:    mevent.NewBroker(),
+		Registry:  memory.NewTable(),
+		Transport: sock.NewTransport(),
+		Signal:    true,
+		Context:   context.Background(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// route between programs using the shared registry
+	if options.Router == nil {
+		options.Router = regRouter.NewRouter(router.Registry(options.Registry))
+	}
+
+	// keep the broker connected as programs come and go
+	if _, ok := options.Broker.(*hostBroker); !ok {
+		options.Broker = &hostBroker{Broker: options.Broker}
+	}
+
+	return &Host{
+		opts:     options,
+		programs: make(map[string]*nitroProgram),
+	}
+}
+
+// Options returns the host options
+func (h *Host) Options() HostOptions {
+	return h.opts
+}
+
+// New returns a program which uses the shared components of the host.
+// It must be passed to Add to be run by the host. The broker, registry,
+// router and transport of the host are applied last so they can't be
+// replaced by the options, including the keys of a Config.
+func (h *Host) New(opts ...Option) *nitroProgram {
+	defaults := []Option{
+		Address(DefaultHostAddress),
+		HandleSignal(false),
+	}
+
+	shared := []Option{
+		func(o *Options) {
+			o.Client.Init(client.Router(h.opts.Router))
+		},
+		Broker(h.opts.Broker),
+		func(o *Options) {
+			if b, ok := h.opts.Broker.(*hostBroker); ok {
+				o.Client.Init(client.Broker(&clientBroker{b}))
+			}
+		},
+		Registry(h.opts.Registry),
+		Transport(h.opts.Transport),
+	}
+
+	options := append(defaults, opts...)
+	return New(append(options, shared...)...)
+}
+
+// Add a program to the host. If the host is running the program is started.
+func (h *Host) Add(p *nitroProgram) error {
+	name := p.Server().Options().Name
+
+	h.Lock()
+	defer h.Unlock()
+
+	if _, ok := h.programs[name]; ok {
+		return fmt.Errorf("program %s already exists", name)
+	}
+
+	if h.started {
+		if err := p.Start(); err != nil {
+			return err
+		}
+	}
+
+	h.programs[name] = p
+	return nil
+}
+
+// Remove a program from the host. If the host is running the program is stopped.
+func (h *Host) Remove(name string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	p, ok := h.programs[name]
+	if !ok {
+		return fmt.Errorf("program %s not found", name)
+	}
+
+	delete(h.programs, name)
+
+	if !h.started {
+		return nil
+	}
+
+	return p.Stop()
+}
+
+// Programs returns the names of the programs in the host.
+// All of them are running while the host is running.
+func (h *Host) Programs() []string {
+	h.RLock()
+	defer h.RUnlock()
+
+	names := make([]string, 0, len(h.programs))
+	for name := range h.programs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Start connects the shared broker and starts every program concurrently.
+// If any program fails to start the programs already started are stopped.
+func (h *Host) Start() error {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.started {
+		return nil
+	}
+
+	if err := h.opts.Broker.Connect(); err != nil {
+		return err
+	}
+
+	var mtx sync.Mutex
+	var started []*nitroProgram
+
+	err := h.each(func(p *nitroProgram) error {
+		if err := p.Start(); err != nil {
+			return err
+		}
+		mtx.Lock()
+		started = append(started, p)
+		mtx.Unlock()
+		return nil
+	})
+
+	if err != nil {
+		for _, p := range started {
+			if serr := p.Stop(); serr != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("Error stopping program %s: %v", p.Server().Options().Name, serr)
+				}
+			}
+		}
+		h.opts.Broker.Disconnect()
+		return err
+	}
+
+	h.started = true
+	return nil
+}
+
+// Stop every program concurrently and disconnect the shared broker
+func (h *Host) Stop() error {
+	h.Lock()
+	defer h.Unlock()
+
+	if !h.started {
+		return nil
+	}
+
+	err := h.each(func(p *nitroProgram) error {
+		return p.Stop()
+	})
+
+	if derr := h.opts.Broker.Disconnect(); derr != nil && err == nil {
+		err = derr
+	}
+
+	h.started = false
+	return err
+}
+
+// Run starts the host and blocks until a signal is received
+// or the context is done, then stops the host
+func (h *Host) Run() error {
+	if err := h.Start(); err != nil {
+		return err
+	}
+
+	ch := make(chan os.Signal, 1)
+	if h.opts.Signal {
+		signal.Notify(ch, shutdownSignals...)
+		defer signal.Stop(ch)
+	}
+
+	select {
+	case sig := <-ch:
+		if logger.V(logger.InfoLevel, logger.DefaultLogger) {
+			logger.Infof("Received signal %s", sig)
+		}
+	case <-h.opts.Context.Done():
+	}
+
+	return h.Stop()
+}
+
+// each calls fn concurrently for every program and combines the errors.
+// The caller must hold the lock.
+func (h *Host) each(fn func(*nitroProgram) error) error {
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	var errs []string
+
+	for name, p := range h.programs {
+		wg.Add(1)
+
+		go func(name string, p *nitroProgram) {
+			defer wg.Done()
+
+			if err := fn(p); err != nil {
+				mtx.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				mtx.Unlock()
+			}
+		}(name, p)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHost(t *testing.T) {
+	host := NewHost(HostSignal(false))
+
+	foo := host.New(Name("test.host.foo"))
+	bar := host.New(Name("test.host.bar"))
+
+	if err := bar.Register(new(Test)); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan *TestRequest, 1)
+	if err := bar.Subscribe("test.host.event", func(ctx context.Context, req *TestRequest) error {
+		events <- req
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*nitroProgram{foo, bar} {
+		if err := host.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// duplicate names are rejected
+	if err := host.Add(host.New(Name("test.host.foo"))); err == nil {
+		t.Fatal("Expected error adding duplicate program")
+	}
+
+	if err := host.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer host.Stop()
+
+	if names := host.Programs(); !reflect.DeepEqual(names, []string{"test.host.bar", "test.host.foo"}) {
+		t.Fatalf("Unexpected programs %v", names)
+	}
+
+	// programs can find each other via the shared registry
+	var rsp TestResponse
+	if err := foo.Execute("test.host.bar", "Test.Call", &TestRequest{Name: "John"}, &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Message != " John" {
+		t.Fatalf("Expected ' John' got %q", rsp.Message)
+	}
+
+	// and events via the shared broker
+	if err := foo.Broadcast("test.host.event", &TestRequest{Name: "Jane"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case req := <-events:
+		if req.Name != "Jane" {
+			t.Fatalf("Expected event for Jane got %s", req.Name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+
+	// programs added at runtime are started
+	baz := host.New(Name("test.host.baz"))
+	if err := baz.Register(new(Test)); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Add(baz); err != nil {
+		t.Fatal(err)
+	}
+	if err := foo.Execute("test.host.baz", "Test.Call", &TestRequest{Name: "John"}, &rsp); err != nil {
+		t.Fatal(err)
+	}
+
+	// removing a program stops it without affecting the others
+	if err := host.Remove("test.host.bar"); err != nil {
+		t.Fatal(err)
+	}
+	if names := host.Programs(); !reflect.DeepEqual(names, []string{"test.host.baz", "test.host.foo"}) {
+		t.Fatalf("Unexpected programs %v", names)
+	}
+	if err := foo.Broadcast("test.host.event", &TestRequest{Name: "Jane"}); err != nil {
+		t.Fatalf("Expected broker to stay connected: %v", err)
+	}
+}
+
+func TestHostBroker(t *testing.T) {
+	host := NewHost(HostSignal(false))
+
+	foo := host.New(Name("test.host.broker"))
+	if err := host.Add(foo); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// publishing connects the client without holding the broker open
+	if err := foo.Broadcast("test.host.broker", &TestRequest{Name: "Jane"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := host.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := host.Options().Broker.(*hostBroker)
+	b.Lock()
+	refs := b.refs
+	b.Unlock()
+
+	if refs != 0 {
+		t.Fatalf("Expected the broker to be released got %d references", refs)
+	}
+}
@@ -0,0 +1,285 @@
+// Package introspect provides a view of the apps, instances, endpoints and events in a cluster
+package introspect
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gonitro/nitro/app/health"
+	"github.com/gonitro/nitro/app/registry"
+)
+
+// App is a named app and every version of it which is running
+type App struct {
+	Name     string     `json:"name"`
+	Versions []*Version `json:"versions"`
+}
+
+// Version of an app with its instances, endpoints and subscribed events
+type Version struct {
+	Version   string            `json:"version"`
+	Metadata  map[string]string `json:"metadata"`
+	Instances []*Instance       `json:"instances"`
+	Endpoints []*Endpoint       `json:"endpoints"`
+	Events    []*Event          `json:"events"`
+}
+
+// Instance is a running instance of an app
+type Instance struct {
+	Id       string            `json:"id"`
+	Address  string            `json:"address"`
+	Metadata map[string]string `json:"metadata"`
+	// Health is the advertised health status, if any
+	Health string `json:"health"`
+}
+
+// Endpoint is a handler which can be called
+type Endpoint struct {
+	// Name of the endpoint e.g Greeter.Hello
+	Name string `json:"name"`
+	// Request type tree
+	Request *registry.Value `json:"request"`
+	// Response type tree. The stream type for a stream.
+	Response *registry.Value `json:"response"`
+	// Stream is true for a bidirectional stream
+	Stream   bool              `json:"stream"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Event is an event an app subscribes to
+type Event struct {
+	// Name of the event e.g user.created
+	Name string `json:"name"`
+	// Handler which processes the event
+	Handler string `json:"handler"`
+	// Message type tree
+	Message  *registry.Value   `json:"message"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Change is a change to an app in the cluster
+type Change struct {
+	// Action is create, update or delete
+	Action string `json:"action"`
+	// App which changed, with only the changed version
+	App *App `json:"app"`
+}
+
+// Inspector introspects the apps in a registry
+type Inspector struct {
+	registry registry.Table
+}
+
+// Watcher streams changes to the apps in the cluster
+type Watcher struct {
+	w registry.Watcher
+}
+
+// New returns an inspector for the registry
+func New(r registry.Table) *Inspector {
+	return &Inspector{registry: r}
+}
+
+// Apps returns every app in the cluster sorted by name
+func (i *Inspector) Apps() ([]*App, error) {
+	list, err := i.registry.List(registry.ListDomain(registry.GlobalDomain))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var apps []*App
+
+	for _, a := range list {
+		if seen[a.Name] {
+			continue
+		}
+		seen[a.Name] = true
+
+		app, err := i.App(a.Name)
+		if err == registry.ErrNotFound {
+			// removed since we listed
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		apps = append(apps, app)
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Name < apps[j].Name
+	})
+
+	return apps, nil
+}
+
+// App returns the app by name or registry.ErrNotFound
+func (i *Inspector) App(name string) (*App, error) {
+	versions, err := i.registry.Get(name, registry.GetDomain(registry.GlobalDomain))
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, registry.ErrNotFound
+	}
+
+	return newApp(name, versions), nil
+}
+
+// Watch returns a watcher for changes to apps in the cluster. If names
+// are specified only changes to those apps are returned.
+func (i *Inspector) Watch(names ...string) (*Watcher, error) {
+	opts := []registry.WatchOption{
+		registry.WatchDomain(registry.GlobalDomain),
+	}
+	if len(names) == 1 {
+		opts = append(opts, registry.WatchApp(names[0]))
+	}
+
+	w, err := i.registry.Watch(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) > 1 {
+		w = &filterWatcher{Watcher: w, names: names}
+	}
+
+	return &Watcher{w: w}, nil
+}
+
+// Next blocks until the next change or the watcher is stopped
+func (w *Watcher) Next() (*Change, error) {
+	res, err := w.w.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Change{
+		Action: res.Action,
+		App:    newApp(res.App.Name, []*registry.App{res.App}),
+	}, nil
+}
+
+// Stop the watcher
+func (w *Watcher) Stop() {
+	w.w.Stop()
+}
+
+// filterWatcher only returns results for the named apps
+type filterWatcher struct {
+	registry.Watcher
+	names []string
+}
+
+func (f *filterWatcher) Next() (*registry.Result, error) {
+	for {
+		res, err := f.Watcher.Next()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range f.names {
+			if res.App.Name == name {
+				return res, nil
+			}
+		}
+	}
+}
+
+func newApp(name string, versions []*registry.App) *App {
+	app := &App{Name: name}
+
+	for _, v := range versions {
+		app.Versions = append(app.Versions, newVersion(v))
+	}
+
+	sort.Slice(app.Versions, func(i, j int) bool {
+		return app.Versions[i].Version < app.Versions[j].Version
+	})
+
+	return app
+}
+
+func newVersion(a *registry.App) *Version {
+	v := &Version{
+		Version:  a.Version,
+		Metadata: a.Metadata,
+	}
+
+	for _, n := range a.Instances {
+		v.Instances = append(v.Instances, &Instance{
+			Id:       n.Id,
+			Address:  n.Address,
+			Metadata: n.Metadata,
+			Health:   n.Metadata[health.MetadataKey],
+		})
+	}
+
+	for _, e := range a.Endpoints {
+		// subscribers are advertised as endpoints
+		if e.Metadata["subscriber"] == "true" {
+			v.Events = append(v.Events, &Event{
+				Name:     e.Metadata["event"],
+				Handler:  e.Name,
+				Message:  e.Request,
+				Metadata: e.Metadata,
+			})
+			continue
+		}
+
+		v.Endpoints = append(v.Endpoints, &Endpoint{
+			Name:     e.Name,
+			Request:  e.Request,
+			Response: e.Response,
+			Stream:   e.Metadata["stream"] == "true",
+			Metadata: e.Metadata,
+		})
+	}
+
+	sort.Slice(v.Instances, func(i, j int) bool {
+		return v.Instances[i].Id < v.Instances[j].Id
+	})
+	sort.Slice(v.Endpoints, func(i, j int) bool {
+		return v.Endpoints[i].Name < v.Endpoints[j].Name
+	})
+	sort.Slice(v.Events, func(i, j int) bool {
+		if v.Events[i].Name == v.Events[j].Name {
+			return v.Events[i].Handler < v.Events[j].Handler
+		}
+		return v.Events[i].Name < v.Events[j].Name
+	})
+
+	return v
+}
+
+// String returns the type tree of a value as a Go like struct definition
+func String(v *registry.Value) string {
+	if v == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	writeValue(&b, v, 0)
+	return b.String()
+}
+
+func writeValue(b *strings.Builder, v *registry.Value, depth int) {
+	indent := strings.Repeat("\t", depth)
+
+	if depth == 0 {
+		b.WriteString(v.Type)
+	} else {
+		b.WriteString(indent + v.Name + " " + v.Type)
+	}
+
+	if len(v.Values) > 0 {
+		b.WriteString(" {\n")
+		for _, f := range v.Values {
+			writeValue(b, f, depth+1)
+		}
+		b.WriteString(indent + "}")
+	}
+
+	b.WriteString("\n")
+}
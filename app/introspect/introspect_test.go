@@ -0,0 +1,152 @@
+package introspect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/health"
+	"github.com/gonitro/nitro/app/registry"
+	"github.com/gonitro/nitro/app/registry/memory"
+)
+
+func testApp(version string, instances ...string) *registry.App {
+	app := &registry.App{
+		Name:    "greeter",
+		Version: version,
+		Endpoints: []*registry.Endpoint{
+			{
+				Name: "Greeter.Hello",
+				Request: &registry.Value{
+					Name: "Request", Type: "Request",
+					Values: []*registry.Value{{Name: "name", Type: "string"}},
+				},
+				Response: &registry.Value{
+					Name: "Response", Type: "Response",
+					Values: []*registry.Value{{Name: "msg", Type: "string"}},
+				},
+				Metadata: map[string]string{},
+			},
+			{
+				Name:     "Greeter.Stream",
+				Request:  &registry.Value{Name: "Context", Type: "Context"},
+				Response: &registry.Value{Name: "Stream", Type: "Stream"},
+				Metadata: map[string]string{"stream": "true"},
+			},
+			{
+				Name:    "Func",
+				Request: &registry.Value{Name: "Event", Type: "Event"},
+				Metadata: map[string]string{
+					"event":      "user.created",
+					"subscriber": "true",
+				},
+			},
+		},
+	}
+
+	for _, id := range instances {
+		app.Instances = append(app.Instances, &registry.Instance{
+			Id:       id,
+			Address:  "127.0.0.1:8080",
+			Metadata: map[string]string{health.MetadataKey: health.Serving},
+		})
+	}
+
+	return app
+}
+
+func TestInspector(t *testing.T) {
+	r := memory.NewTable()
+
+	for _, app := range []*registry.App{
+		testApp("1.0.0", "greeter-1", "greeter-2"),
+		testApp("2.0.0", "greeter-3"),
+	} {
+		if err := r.Add(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i := New(r)
+
+	apps, err := i.Apps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apps) != 1 || apps[0].Name != "greeter" {
+		t.Fatalf("Expected greeter app got %+v", apps)
+	}
+
+	versions := apps[0].Versions
+	if len(versions) != 2 || versions[0].Version != "1.0.0" || versions[1].Version != "2.0.0" {
+		t.Fatalf("Expected versions 1.0.0 and 2.0.0 got %+v", versions)
+	}
+
+	v := versions[0]
+	if len(v.Instances) != 2 || v.Instances[0].Id != "greeter-1" || v.Instances[0].Health != health.Serving {
+		t.Fatalf("Unexpected instances %+v", v.Instances)
+	}
+
+	if len(v.Endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints got %d", len(v.Endpoints))
+	}
+	if ep := v.Endpoints[0]; ep.Name != "Greeter.Hello" || ep.Stream || ep.Request.Values[0].Name != "name" {
+		t.Fatalf("Unexpected endpoint %+v", ep)
+	}
+	if ep := v.Endpoints[1]; ep.Name != "Greeter.Stream" || !ep.Stream {
+		t.Fatalf("Unexpected endpoint %+v", ep)
+	}
+
+	if len(v.Events) != 1 || v.Events[0].Name != "user.created" || v.Events[0].Message.Type != "Event" {
+		t.Fatalf("Unexpected events %+v", v.Events)
+	}
+
+	expect := "Request {\n\tname string\n}\n"
+	if s := String(v.Endpoints[0].Request); s != expect {
+		t.Fatalf("Expected %q got %q", expect, s)
+	}
+
+	if _, err := i.App("missing"); err != registry.ErrNotFound {
+		t.Fatalf("Expected not found got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	r := memory.NewTable()
+	i := New(r)
+
+	w, err := i.Watch("greeter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	changes := make(chan *Change, 1)
+	go func() {
+		c, err := w.Next()
+		if err != nil {
+			return
+		}
+		changes <- c
+	}()
+
+	// changes to other apps are ignored
+	other := testApp("1.0.0", "other-1")
+	other.Name = "other"
+	if err := r.Add(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(testApp("1.0.0", "greeter-1")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case c := <-changes:
+		// create and update events may arrive in any order
+		if c.Action == "delete" || c.App.Name != "greeter" || len(c.App.Versions[0].Endpoints) != 2 {
+			t.Fatalf("Unexpected change %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for change")
+	}
+}
@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"sort"
@@ -20,6 +21,32 @@ func init() {
 	DefaultLogger = NewHelper(NewLogger(WithLevel(lvl)))
 }
 
+// tees receive the entries of every default logger as well as its output
+var tees struct {
+	sync.RWMutex
+	writers []io.Writer
+}
+
+// Tee writes the entries of every logger created by NewLogger to w as well
+// as its output, including loggers replaced or initialised later
+func Tee(w io.Writer) {
+	tees.Lock()
+	tees.writers = append(tees.writers, w)
+	tees.Unlock()
+}
+
+// write the entry to the output and the tees
+func write(out io.Writer, entry string) {
+	io.WriteString(out, entry)
+
+	tees.RLock()
+	defer tees.RUnlock()
+
+	for _, w := range tees.writers {
+		io.WriteString(w, entry)
+	}
+}
+
 type defaultLogger struct {
 	sync.RWMutex
 	opts Options
@@ -27,6 +54,9 @@ type defaultLogger struct {
 
 // Init(opts...) should only overwrite provided options
 func (l *defaultLogger) Init(opts ...Option) error {
+	l.Lock()
+	defer l.Unlock()
+
 	for _, o := range opts {
 		o(&l.opts)
 	}
@@ -37,11 +67,15 @@ func (l *defaultLogger) String() string {
 	return "default"
 }
 
+// Fields returns a copy of the logger with the fields so loggers
+// sharing it can log with their own fields concurrently
 func (l *defaultLogger) Fields(fields map[string]interface{}) Logger {
-	l.Lock()
-	l.opts.Fields = copyFields(fields)
-	l.Unlock()
-	return l
+	l.RLock()
+	opts := l.opts
+	l.RUnlock()
+
+	opts.Fields = copyFields(fields)
+	return &defaultLogger{opts: opts}
 }
 
 func copyFields(src map[string]interface{}) map[string]interface{} {
@@ -106,7 +140,7 @@ func (l *defaultLogger) Log(level Level, v ...interface{}) {
 	}
 
 	t := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Printf("%s %s %v\n", t, metadata, fmt.Sprint(v...))
+	write(os.Stdout, fmt.Sprintf("%s %s %v\n", t, metadata, fmt.Sprint(v...)))
 }
 
 func (l *defaultLogger) Logf(level Level, format string, v ...interface{}) {
@@ -138,7 +172,7 @@ func (l *defaultLogger) Logf(level Level, format string, v ...interface{}) {
 	}
 
 	t := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Printf("%s %s %v\n", t, metadata, fmt.Sprintf(format, v...))
+	write(os.Stdout, fmt.Sprintf("%s %s %v\n", t, metadata, fmt.Sprintf(format, v...)))
 }
 
 func (l *defaultLogger) Options() Options {
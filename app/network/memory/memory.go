@@ -54,13 +54,15 @@ type memoryTransport struct {
 }
 
 func (ms *memorySocket) Recv(m *network.Message) error {
+	// don't hold the lock while blocked otherwise Close can't proceed
 	ms.RLock()
-	defer ms.RUnlock()
-
 	ctx := ms.ctx
-	if ms.timeout > 0 {
+	timeout := ms.timeout
+	ms.RUnlock()
+
+	if timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ms.ctx, ms.timeout)
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
 
@@ -86,13 +88,15 @@ func (ms *memorySocket) Remote() string {
 }
 
 func (ms *memorySocket) Send(m *network.Message) error {
+	// don't hold the lock while blocked otherwise Close can't proceed
 	ms.RLock()
-	defer ms.RUnlock()
-
 	ctx := ms.ctx
-	if ms.timeout > 0 {
+	timeout := ms.timeout
+	ms.RUnlock()
+
+	if timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ms.ctx, ms.timeout)
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
 
@@ -5,9 +5,20 @@ import (
 	"time"
 
 	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/config"
 	"github.com/gonitro/nitro/app/event"
+	mevent "github.com/gonitro/nitro/app/event/memory"
+	"github.com/gonitro/nitro/app/health"
+	"github.com/gonitro/nitro/app/logger"
 	"github.com/gonitro/nitro/app/network"
+	tmem "github.com/gonitro/nitro/app/network/memory"
+	sock "github.com/gonitro/nitro/app/network/socket"
 	"github.com/gonitro/nitro/app/registry"
+	"github.com/gonitro/nitro/app/registry/memory"
+	"github.com/gonitro/nitro/app/router"
+	"github.com/gonitro/nitro/app/router/dns"
+	regRouter "github.com/gonitro/nitro/app/router/registry"
+	"github.com/gonitro/nitro/app/router/static"
 	"github.com/gonitro/nitro/app/server"
 )
 
@@ -16,6 +27,17 @@ type Options struct {
 	Client   client.Client
 	Server   server.Server
 	Registry registry.Table
+	Health   *health.Checker
+
+	// The interval on which health checks are run
+	HealthInterval time.Duration
+
+	// Signal enables trapping of SIGINT/SIGTERM in Run
+	Signal bool
+
+	// Hooks are started in order before the server
+	// and stopped in reverse order after it
+	Hooks []Hook
 
 	// Before and After funcs
 	BeforeStart []func() error
@@ -30,12 +52,51 @@ type Options struct {
 
 type Option func(*Options)
 
+// HostOptions are the shared components of a Host
+type HostOptions struct {
+	Broker    event.Broker
+	Registry  registry.Table
+	Router    router.Router
+	Transport network.Transport
+
+	// Signal enables trapping of SIGINT/SIGTERM in Run
+	Signal bool
+
+	// Other options for implementations of the interface
+	// can be stored in a context
+	Context context.Context
+}
+
+type HostOption func(*HostOptions)
+
+// ExecuteOptions are used to configure a call made via ExecuteContext or Stream
+type ExecuteOptions struct {
+	// CallOptions passed to the client Call
+	CallOptions []client.CallOption
+	// RequestOptions passed when creating the request
+	RequestOptions []client.RequestOption
+}
+
+type ExecuteOption func(*ExecuteOptions)
+
+// BroadcastOptions are used to configure an event sent via BroadcastContext
+type BroadcastOptions struct {
+	// PublishOptions passed to the client Publish
+	PublishOptions []client.PublishOption
+	// MessageOptions passed when creating the message
+	MessageOptions []client.MessageOption
+}
+
+type BroadcastOption func(*BroadcastOptions)
+
 func Broker(b event.Broker) Option {
 	return func(o *Options) {
 		o.Broker = b
 		// Update Client and Server
 		o.Client.Init(client.Broker(b))
 		o.Server.Init(server.Broker(b))
+		// Update health check
+		o.Health.Add("broker", health.Broker(b))
 	}
 }
 
@@ -72,6 +133,8 @@ func Registry(r registry.Table) Option {
 		o.Broker.Init(event.Registry(r))
 		// Update router
 		o.Client.Init(client.Registry(r))
+		// Update health check
+		o.Health.Add("registry", health.Registry(r))
 	}
 }
 
@@ -83,6 +146,122 @@ func Transport(t network.Transport) Option {
 	}
 }
 
+// HandleSignal toggles automatic installation of the signal handler that
+// traps SIGINT and SIGTERM to gracefully stop the app. Defaults to true.
+func HandleSignal(b bool) Option {
+	return func(o *Options) {
+		o.Signal = b
+	}
+}
+
+// HealthCheck adds a named check which is run to determine the health of the app
+func HealthCheck(name string, fn health.CheckFunc) Option {
+	return func(o *Options) {
+		o.Health.Add(name, fn)
+	}
+}
+
+// HealthInterval sets the interval on which health checks are run
+func HealthInterval(t time.Duration) Option {
+	return func(o *Options) {
+		o.HealthInterval = t
+	}
+}
+
+// Config applies the declarative config loaded from a file and the environment.
+// Only the keys which were set are applied. Options passed after Config take
+// precedence while those passed before are overridden. The registry and broker
+// keys replace those of the program, except in a Host where they're shared.
+func Config(c *config.Config) Option {
+	return func(o *Options) {
+		var copts []client.Option
+		var sopts []server.Option
+
+		if c.IsSet("name") {
+			sopts = append(sopts, server.Name(c.Name))
+		}
+		if c.IsSet("version") {
+			sopts = append(sopts, server.Version(c.Version))
+		}
+		if c.IsSet("address") {
+			sopts = append(sopts, server.Address(c.Address))
+		}
+		if c.IsSet("server.add_ttl") {
+			sopts = append(sopts, server.AddTTL(c.Server.AddTTL))
+		}
+		if c.IsSet("server.add_interval") {
+			sopts = append(sopts, server.AddInterval(c.Server.AddInterval))
+		}
+		if c.IsSet("server.drain_timeout") {
+			sopts = append(sopts, server.DrainTimeout(c.Server.DrainTimeout))
+		}
+		if c.IsSet("client.retries") {
+			copts = append(copts, client.Retries(c.Client.Retries))
+		}
+		if c.IsSet("client.pool_size") {
+			copts = append(copts, client.PoolSize(c.Client.PoolSize))
+		}
+		if c.IsSet("client.request_timeout") {
+			copts = append(copts, client.RequestTimeout(c.Client.RequestTimeout))
+		}
+		if c.IsSet("client.dial_timeout") {
+			copts = append(copts, client.DialTimeout(c.Client.DialTimeout))
+		}
+
+		o.Server.Init(sopts...)
+		o.Client.Init(copts...)
+
+		if c.IsSet("log_level") {
+			lvl, _ := logger.GetLevel(c.LogLevel)
+			logger.Init(logger.WithLevel(lvl))
+		}
+
+		// the values below are validated by the config package
+		if c.IsSet("registry") {
+			switch c.Registry {
+			case "memory":
+				Registry(memory.NewTable())(o)
+			}
+		}
+
+		if c.IsSet("broker") {
+			switch c.Broker {
+			case "memory":
+				Broker(mevent.NewBroker())(o)
+			}
+		}
+
+		if c.IsSet("transport") || c.TLSConfig() != nil {
+			var topts []network.Option
+			if tc := c.TLSConfig(); tc != nil {
+				topts = append(topts, network.Secure(true), network.TLSConfig(tc))
+			}
+
+			switch c.Transport {
+			case "memory":
+				Transport(tmem.NewTransport(topts...))(o)
+			default:
+				Transport(sock.NewTransport(topts...))(o)
+			}
+		}
+
+		if c.IsSet("router") {
+			var r router.Router
+
+			switch c.Router {
+			case "dns":
+				r = dns.NewRouter()
+			case "registry":
+				r = regRouter.NewRouter(router.Registry(o.Registry))
+			default:
+				r = static.NewRouter()
+			}
+
+			o.Client.Init(client.Router(r))
+		}
+	}
+}
+
 // Convenience options
 
 // Address sets the address of the server
@@ -127,6 +306,14 @@ func AddInterval(t time.Duration) Option {
 	}
 }
 
+// DrainTimeout sets the max time to wait for in-flight requests
+// and subscriber callbacks to finish when the app is stopped
+func DrainTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.Server.Init(server.DrainTimeout(t))
+	}
+}
+
 // WrapClient is a convenience method for wrapping a Client with
 // some middleware component. A list of wrappers can be provided.
 // Wrappers are applied in reverse order so the last is executed first.
@@ -160,6 +347,27 @@ func WrapHandler(w ...server.HandlerWrapper) Option {
 	}
 }
 
+// WrapStream adds a stream Wrapper to a list of options passed into the server
+func WrapStream(w ...server.StreamWrapper) Option {
+	return func(o *Options) {
+		var wrappers []server.Option
+
+		for _, wrap := range w {
+			wrappers = append(wrappers, server.WrapStream(wrap))
+		}
+
+		// Init once
+		o.Server.Init(wrappers...)
+	}
+}
+
+// WrapCallStream adds to the wrappers applied to each stream created by the client
+func WrapCallStream(w ...client.StreamWrapper) Option {
+	return func(o *Options) {
+		o.Client.Init(client.WrapStream(w...))
+	}
+}
+
 // WrapSubscriber adds a subscriber Wrapper to a list of options passed into the server
 func WrapSubscriber(w ...server.SubscriberWrapper) Option {
 	return func(o *Options) {
@@ -174,28 +382,121 @@ func WrapSubscriber(w ...server.SubscriberWrapper) Option {
 	}
 }
 
+// Hooks adds named lifecycle hooks. Hooks are started in order before the server
+// and stopped in reverse order after it. If startup fails the hooks already
+// started are stopped.
+func Hooks(h ...Hook) Option {
+	return func(o *Options) {
+		o.Hooks = append(o.Hooks, h...)
+	}
+}
+
 // Before and Afters
 
+// BeforeStart runs the func before the hooks are started. The BeforeStart and
+// AfterStop funcs are run as the first hook so if the program fails to start
+// after they ran the AfterStop funcs are run to clean up.
 func BeforeStart(fn func() error) Option {
 	return func(o *Options) {
 		o.BeforeStart = append(o.BeforeStart, fn)
 	}
 }
 
+// BeforeStop runs the func once the server is stopped and its requests drained
 func BeforeStop(fn func() error) Option {
 	return func(o *Options) {
 		o.BeforeStop = append(o.BeforeStop, fn)
 	}
 }
 
+// AfterStart runs the func once the server is started. If it fails the
+// program is stopped, running the BeforeStop funcs, hooks and AfterStop funcs.
 func AfterStart(fn func() error) Option {
 	return func(o *Options) {
 		o.AfterStart = append(o.AfterStart, fn)
 	}
 }
 
+// AfterStop runs the func after the hooks are stopped, including
+// when the program fails to start after the BeforeStart funcs ran
 func AfterStop(fn func() error) Option {
 	return func(o *Options) {
 		o.AfterStop = append(o.AfterStop, fn)
 	}
 }
+
+// Execute and Broadcast options
+
+// WithCallOptions sets the client call options e.g retries, timeouts, addresses
+func WithCallOptions(opts ...client.CallOption) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.CallOptions = append(o.CallOptions, opts...)
+	}
+}
+
+// WithRequestOptions sets the client request options e.g content type
+func WithRequestOptions(opts ...client.RequestOption) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.RequestOptions = append(o.RequestOptions, opts...)
+	}
+}
+
+// WithPublishOptions sets the client publish options e.g exchange
+func WithPublishOptions(opts ...client.PublishOption) BroadcastOption {
+	return func(o *BroadcastOptions) {
+		o.PublishOptions = append(o.PublishOptions, opts...)
+	}
+}
+
+// WithMessageOptions sets the client message options e.g content type
+func WithMessageOptions(opts ...client.MessageOption) BroadcastOption {
+	return func(o *BroadcastOptions) {
+		o.MessageOptions = append(o.MessageOptions, opts...)
+	}
+}
+
+// Host options
+
+// HostBroker sets the broker shared by programs in the host
+func HostBroker(b event.Broker) HostOption {
+	return func(o *HostOptions) {
+		o.Broker = b
+	}
+}
+
+// HostRegistry sets the registry shared by programs in the host
+func HostRegistry(r registry.Table) HostOption {
+	return func(o *HostOptions) {
+		o.Registry = r
+	}
+}
+
+// HostRouter sets the router used by programs in the host to find each other
+func HostRouter(r router.Router) HostOption {
+	return func(o *HostOptions) {
+		o.Router = r
+	}
+}
+
+// HostTransport sets the network transport shared by programs in the host
+func HostTransport(t network.Transport) HostOption {
+	return func(o *HostOptions) {
+		o.Transport = t
+	}
+}
+
+// HostContext specifies a context for the host.
+// Can be used to signal shutdown of the host.
+func HostContext(ctx context.Context) HostOption {
+	return func(o *HostOptions) {
+		o.Context = ctx
+	}
+}
+
+// HostSignal toggles trapping of SIGINT and SIGTERM
+// to gracefully stop the host. Defaults to true.
+func HostSignal(b bool) HostOption {
+	return func(o *HostOptions) {
+		o.Signal = b
+	}
+}
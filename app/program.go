@@ -3,10 +3,19 @@ package app
 
 import (
 	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/gonitro/nitro/app/client"
 	rpcClient "github.com/gonitro/nitro/app/client/rpc"
 	mevent "github.com/gonitro/nitro/app/event/memory"
+	"github.com/gonitro/nitro/app/health"
+	"github.com/gonitro/nitro/app/introspect"
+	"github.com/gonitro/nitro/app/logger"
+	"github.com/gonitro/nitro/app/metadata"
 	sock "github.com/gonitro/nitro/app/network/socket"
 	"github.com/gonitro/nitro/app/registry/memory"
 	"github.com/gonitro/nitro/app/router/static"
@@ -20,18 +29,83 @@ type Program interface {
 	Name(string)
 	// Execute a function in a remote program
 	Execute(prog, fn string, req, rsp interface{}) error
+	// ExecuteContext executes a function in a remote program using the given context and options
+	ExecuteContext(ctx context.Context, prog, fn string, req, rsp interface{}, opts ...ExecuteOption) error
 	// Broadcast an event to subscribers
 	Broadcast(event string, msg interface{}) error
+	// BroadcastContext broadcasts an event to subscribers using the given context and options
+	BroadcastContext(ctx context.Context, event string, msg interface{}, opts ...BroadcastOption) error
+	// Stream opens a bidirectional stream to a function in a remote program. The request, if not nil, is sent as the first message.
+	Stream(ctx context.Context, prog, fn string, req interface{}, opts ...ExecuteOption) (Stream, error)
 	// Register a function e.g a public Go struct/method with signature func(context.Context, *Request, *Response) error
-	Register(fn interface{}) error
+	// or func(context.Context, server.Stream) error for a bidirectional stream. A standalone func must be named
+	// using the server.EndpointName option e.g server.EndpointName("Greeter.Hello")
+	Register(fn interface{}, opts ...server.HandlerOption) error
 	// Subscribe to broadcast events. Signature is public Go func or struct with signature func(context.Context, *Message) error
 	Subscribe(event string, fn interface{}) error
 	// Run the application program
 	Run() error
 }
 
+// Stream is a bidirectional stream with a function in a remote program
+type Stream interface {
+	// Context for the stream
+	Context() context.Context
+	// Send a message to the remote function
+	Send(msg interface{}) error
+	// Recv decodes the next message from the remote function into msg.
+	// io.EOF is returned when the remote function has returned.
+	Recv(msg interface{}) error
+	// Error returns the last stream error
+	Error() error
+	// Close the stream
+	Close() error
+}
+
+// stream is a Stream backed by a client stream
+type stream struct {
+	s client.Stream
+}
+
+func (s *stream) Context() context.Context {
+	return s.s.Context()
+}
+
+func (s *stream) Send(msg interface{}) error {
+	return s.s.Send(msg)
+}
+
+func (s *stream) Recv(msg interface{}) error {
+	return s.s.Recv(msg)
+}
+
+func (s *stream) Error() error {
+	return s.s.Error()
+}
+
+func (s *stream) Close() error {
+	return s.s.Close()
+}
+
+var (
+	// shutdownSignals are the signals trapped by Run to stop the program
+	shutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+)
+
+// registrar is implemented by servers which can be re-registered on demand
+type registrar interface {
+	Add() error
+}
+
 type nitroProgram struct {
 	opts Options
+
+	// hooks which have been started
+	hooks []Hook
+
+	// stops the health watcher
+	exit chan bool
+	done chan bool
 }
 
 func (s *nitroProgram) Name(name string) {
@@ -55,17 +129,61 @@ func (s *nitroProgram) Options() Options {
 }
 
 func (s *nitroProgram) Execute(name, ep string, req, rsp interface{}) error {
-	r := s.Client().NewRequest(name, ep, req)
-	return s.Client().Call(context.Background(), r, rsp)
+	return s.ExecuteContext(context.Background(), name, ep, req, rsp)
+}
+
+func (s *nitroProgram) ExecuteContext(ctx context.Context, name, ep string, req, rsp interface{}, opts ...ExecuteOption) error {
+	var options ExecuteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	r := s.Client().NewRequest(name, ep, req, options.RequestOptions...)
+	return s.Client().Call(ctx, r, rsp, options.CallOptions...)
+}
+
+func (s *nitroProgram) Stream(ctx context.Context, name, ep string, req interface{}, opts ...ExecuteOption) (Stream, error) {
+	var options ExecuteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	ropts := append([]client.RequestOption{client.StreamingRequest()}, options.RequestOptions...)
+	r := s.Client().NewRequest(name, ep, nil, ropts...)
+
+	cs, err := s.Client().Stream(ctx, r, options.CallOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the server discards the body which opens the stream so
+	// the request is sent as the first message of the stream
+	if req != nil {
+		if err := cs.Send(req); err != nil {
+			cs.Close()
+			return nil, err
+		}
+	}
+
+	return &stream{cs}, nil
 }
 
 func (s *nitroProgram) Broadcast(event string, msg interface{}) error {
-	m := s.Client().NewMessage(event, msg)
-	return s.Client().Publish(context.Background(), m)
+	return s.BroadcastContext(context.Background(), event, msg)
+}
+
+func (s *nitroProgram) BroadcastContext(ctx context.Context, event string, msg interface{}, opts ...BroadcastOption) error {
+	var options BroadcastOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	m := s.Client().NewMessage(event, msg, options.MessageOptions...)
+	return s.Client().Publish(ctx, m, options.PublishOptions...)
 }
 
-func (s *nitroProgram) Register(v interface{}) error {
-	h := s.Server().NewHandler(v)
+func (s *nitroProgram) Register(v interface{}, opts ...server.HandlerOption) error {
+	h := s.Server().NewHandler(v, opts...)
 	return s.Server().Handle(h)
 }
 
@@ -82,23 +200,38 @@ func (s *nitroProgram) Server() server.Server {
 	return s.opts.Server
 }
 
+// Inspect returns an inspector for the apps reachable via the registry
+func (s *nitroProgram) Inspect() *introspect.Inspector {
+	return introspect.New(s.opts.Registry)
+}
+
 func (s *nitroProgram) String() string {
 	return "rpc"
 }
 
 func (s *nitroProgram) Start() error {
-	for _, fn := range s.opts.BeforeStart {
-		if err := fn(); err != nil {
-			return err
-		}
+	// start the hooks, rolling back on failure
+	hooks, err := startHooks(s.opts.Context, lifecycle(s.opts))
+	if err != nil {
+		return err
 	}
+	s.hooks = hooks
 
 	if err := s.opts.Server.Start(); err != nil {
+		s.shutdown()
 		return err
 	}
 
+	// advertise health changes and start checking
+	s.exit = make(chan bool)
+	s.done = make(chan bool)
+	go s.watchHealth(s.exit, s.done)
+	s.opts.Health.Start(s.opts.HealthInterval)
+
 	for _, fn := range s.opts.AfterStart {
 		if err := fn(); err != nil {
+			// stop everything already started
+			s.shutdown()
 			return err
 		}
 	}
@@ -106,26 +239,91 @@ func (s *nitroProgram) Start() error {
 	return nil
 }
 
+// Stop deregisters the program and drains the requests in flight
+// before running the BeforeStop funcs, hooks and AfterStop funcs
 func (s *nitroProgram) Stop() error {
-	var gerr error
+	if errs := s.shutdown(); len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
 
-	for _, fn := range s.opts.BeforeStop {
-		if err := fn(); err != nil {
-			gerr = err
-		}
+	return nil
+}
+
+// shutdown stops the health checks, server and started hooks in that order
+func (s *nitroProgram) shutdown() []string {
+	return append(s.stopServer(), s.stopHooks()...)
+}
+
+// stopServer stops the health checks then the server
+func (s *nitroProgram) stopServer() []string {
+	var errs []string
+
+	// stop health checks before we deregister
+	s.opts.Health.Stop()
+	if s.exit != nil {
+		close(s.exit)
+		<-s.done
+		s.exit = nil
 	}
 
+	// deregisters, stops accepting connections and
+	// waits for in-flight requests to drain
 	if err := s.opts.Server.Stop(); err != nil {
-		return err
+		errs = append(errs, err.Error())
 	}
 
-	for _, fn := range s.opts.AfterStop {
-		if err := fn(); err != nil {
-			gerr = err
+	return errs
+}
+
+// stopHooks stops the started hooks in reverse order
+func (s *nitroProgram) stopHooks() []string {
+	var errs []string
+
+	// the program context may be done so stop with a new one
+	for _, err := range stopHooks(context.Background(), s.hooks) {
+		errs = append(errs, err.Error())
+	}
+	s.hooks = nil
+
+	return errs
+}
+
+// watchHealth advertises changes in health status in the registry
+func (s *nitroProgram) watchHealth(exit, done chan bool) {
+	defer close(done)
+
+	updates, stop := s.opts.Health.Watch()
+	defer stop()
+
+	for {
+		select {
+		case status := <-updates:
+			if err := s.advertise(status); err != nil {
+				if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+					logger.Errorf("Error advertising health status %s: %v", status.Status, err)
+				}
+			}
+		case <-exit:
+			return
 		}
 	}
+}
+
+// advertise sets the health status in the server metadata and re-registers
+func (s *nitroProgram) advertise(status *health.Status) error {
+	md := metadata.Copy(s.opts.Server.Options().Metadata)
+	md[health.MetadataKey] = status.Status
+
+	if err := s.opts.Server.Init(server.Metadata(md)); err != nil {
+		return err
+	}
 
-	return gerr
+	// re-register right away so routers can skip unhealthy instances
+	if r, ok := s.opts.Server.(registrar); ok {
+		return r.Add()
+	}
+
+	return nil
 }
 
 func (s *nitroProgram) Run() error {
@@ -133,8 +331,20 @@ func (s *nitroProgram) Run() error {
 		return err
 	}
 
-	// wait on context cancel
-	<-s.opts.Context.Done()
+	ch := make(chan os.Signal, 1)
+	if s.opts.Signal {
+		signal.Notify(ch, shutdownSignals...)
+		defer signal.Stop(ch)
+	}
+
+	// wait on kill signal or context cancel
+	select {
+	case sig := <-ch:
+		if logger.V(logger.InfoLevel, logger.DefaultLogger) {
+			logger.Infof("Received signal %s", sig)
+		}
+	case <-s.opts.Context.Done():
+	}
 
 	return s.Stop()
 }
@@ -147,6 +357,11 @@ func New(opts ...Option) *nitroProgram {
 	r := memory.NewTable()
 	t := sock.NewTransport()
 	st := static.NewRouter()
+	h := health.NewChecker()
+
+	// default health checks
+	h.Add("broker", health.Broker(b))
+	h.Add("registry", health.Registry(r))
 
 	// set client options
 	c.Init(
@@ -161,21 +376,30 @@ func New(opts ...Option) *nitroProgram {
 		server.Broker(b),
 		server.Registry(r),
 		server.Transport(t),
+		server.Wait(nil),
 	)
 
 	// define local opts
 	options := Options{
-		Broker:   b,
-		Client:   c,
-		Server:   s,
-		Registry: r,
-		Context:  context.Background(),
+		Broker:         b,
+		Client:         c,
+		Server:         s,
+		Registry:       r,
+		Health:         h,
+		HealthInterval: health.DefaultInterval,
+		Signal:         true,
+		Context:        context.Background(),
 	}
 
 	for _, o := range opts {
 		o(&options)
 	}
 
+	// register the internal health handler
+	options.Server.Handle(
+		options.Server.NewHandler(health.NewHandler(h), server.InternalHandler(true)),
+	)
+
 	return &nitroProgram{
 		opts: options,
 	}
@@ -0,0 +1,499 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	"github.com/gonitro/nitro/app/config"
+	"github.com/gonitro/nitro/app/event"
+	"github.com/gonitro/nitro/app/health"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/server"
+)
+
+type TestRequest struct {
+	Name string
+}
+
+type TestResponse struct {
+	Message string
+}
+
+type Test struct{}
+
+func (t *Test) Call(ctx context.Context, req *TestRequest, rsp *TestResponse) error {
+	md, _ := metadata.FromContext(ctx)
+	rsp.Message = md["Foo"] + " " + req.Name
+	return nil
+}
+
+func (t *Test) Stream(ctx context.Context, stream server.Stream) error {
+	for {
+		var req TestRequest
+		if err := stream.Recv(&req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.Send(&TestResponse{Message: "Hello " + req.Name}); err != nil {
+			return err
+		}
+	}
+}
+
+func testProgram(t *testing.T, name string) *nitroProgram {
+	prog := New(
+		Name(name),
+		Address("127.0.0.1:0"),
+	)
+
+	if err := prog.Register(new(Test)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := prog.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return prog
+}
+
+func TestExecuteContext(t *testing.T) {
+	prog := testProgram(t, "test.execute")
+	defer prog.Stop()
+
+	addr := prog.Server().Options().Address
+
+	ctx := metadata.Set(context.Background(), "Foo", "Hello")
+
+	var rsp TestResponse
+	err := prog.ExecuteContext(ctx, "test.execute", "Test.Call", &TestRequest{Name: "John"}, &rsp,
+		WithCallOptions(client.WithAddress(addr)),
+		WithRequestOptions(client.WithContentType("application/json")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.Message != "Hello John" {
+		t.Fatalf("Expected 'Hello John' got %q", rsp.Message)
+	}
+}
+
+func TestStream(t *testing.T) {
+	prog := testProgram(t, "test.stream")
+	defer prog.Stop()
+
+	addr := prog.Server().Options().Address
+
+	stream, err := prog.Stream(context.Background(), "test.stream", "Test.Stream", &TestRequest{Name: "John"},
+		WithCallOptions(client.WithAddress(addr)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if _, ok := stream.(client.Stream); ok {
+		t.Fatal("Expected a program stream got a client stream")
+	}
+
+	// the request is the first message
+	var rsp TestResponse
+	if err := stream.Recv(&rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Message != "Hello John" {
+		t.Fatalf("Expected 'Hello John' got %q", rsp.Message)
+	}
+
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("John%d", i)
+		if err := stream.Send(&TestRequest{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+
+		var rsp TestResponse
+		if err := stream.Recv(&rsp); err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.Message != "Hello "+name {
+			t.Fatalf("Expected 'Hello %s' got %q", name, rsp.Message)
+		}
+	}
+}
+
+func TestBroadcastContext(t *testing.T) {
+	prog := New()
+
+	var exchange string
+
+	if err := prog.Options().Broker.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := prog.Options().Broker.Subscribe("exchange", func(m *event.Message) error {
+		exchange = m.Header["Event"]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	err = prog.BroadcastContext(context.Background(), "test.event", &TestRequest{Name: "John"},
+		WithPublishOptions(client.WithExchange("exchange")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exchange != "test.event" {
+		t.Fatalf("Expected event 'test.event' got %q", exchange)
+	}
+}
+
+type Slow struct {
+	started chan bool
+	delay   time.Duration
+	// set once the call has returned
+	done int32
+}
+
+func (s *Slow) Call(ctx context.Context, req *TestRequest, rsp *TestResponse) error {
+	s.started <- true
+	time.Sleep(s.delay)
+	rsp.Message = "done"
+	atomic.StoreInt32(&s.done, 1)
+	return nil
+}
+
+func TestStopDrain(t *testing.T) {
+	testData := []struct {
+		drain time.Duration
+		err   bool
+	}{
+		{time.Second * 5, false},
+		{time.Millisecond * 10, true},
+	}
+
+	for _, d := range testData {
+		prog := New(
+			Name("test.drain"),
+			Address("127.0.0.1:0"),
+			DrainTimeout(d.drain),
+		)
+
+		slow := &Slow{started: make(chan bool, 1), delay: time.Millisecond * 200}
+
+		if err := prog.Register(slow); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := prog.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		addr := prog.Server().Options().Address
+		errCh := make(chan error, 1)
+
+		go func() {
+			var rsp TestResponse
+			errCh <- prog.ExecuteContext(context.Background(), "test.drain", "Slow.Call", &TestRequest{}, &rsp,
+				WithCallOptions(client.WithAddress(addr)),
+			)
+		}()
+
+		// wait for the request to be in-flight
+		<-slow.started
+
+		err := prog.Stop()
+		if d.err && err == nil {
+			t.Fatal("Expected drain timeout error got nil")
+		}
+		if !d.err && err != nil {
+			t.Fatalf("Expected nil error got %v", err)
+		}
+
+		if err := <-errCh; !d.err && err != nil {
+			t.Fatalf("Expected in-flight call to complete got %v", err)
+		}
+	}
+}
+
+func TestStopOrder(t *testing.T) {
+	var order []string
+	var drained bool
+
+	slow := &Slow{started: make(chan bool, 1), delay: time.Millisecond * 100}
+	errCh := make(chan error, 1)
+
+	prog := New(
+		Name("test.order"),
+		Address("127.0.0.1:0"),
+		BeforeStop(func() error {
+			// the in-flight call has completed by now
+			drained = atomic.LoadInt32(&slow.done) == 1
+			order = append(order, "before")
+			return nil
+		}),
+		Hooks(Hook{
+			Name: "hook",
+			Stop: func(ctx context.Context) error {
+				order = append(order, "hook")
+				return nil
+			},
+		}),
+		AfterStop(func() error {
+			order = append(order, "after")
+			return nil
+		}),
+	)
+
+	if err := prog.Register(slow); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := prog.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := prog.Server().Options().Address
+
+	go func() {
+		var rsp TestResponse
+		errCh <- prog.ExecuteContext(context.Background(), "test.order", "Slow.Call", &TestRequest{}, &rsp,
+			WithCallOptions(client.WithAddress(addr)),
+		)
+	}()
+
+	<-slow.started
+
+	if err := prog.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !drained {
+		t.Fatal("Expected in-flight call to complete before the stop funcs")
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Expected in-flight call to succeed got %v", err)
+	}
+	if fmt.Sprint(order) != "[before hook after]" {
+		t.Fatalf("Expected stop order [before hook after] got %v", order)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	var fail int32
+
+	prog := New(
+		Name("test.health"),
+		Address("127.0.0.1:0"),
+		HealthInterval(time.Millisecond*20),
+		HealthCheck("custom", func(ctx context.Context) error {
+			if atomic.LoadInt32(&fail) == 1 {
+				return fmt.Errorf("failed")
+			}
+			return nil
+		}),
+	)
+
+	if err := prog.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Stop()
+
+	addr := prog.Server().Options().Address
+
+	check := func(status string) {
+		var rsp health.Response
+		if err := prog.ExecuteContext(context.Background(), "test.health", "Health.Check", &health.Request{}, &rsp,
+			WithCallOptions(client.WithAddress(addr)),
+		); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Status != status {
+			t.Fatalf("Expected status %s got %s: %v", status, rsp.Status, rsp.Checks)
+		}
+	}
+
+	check(health.Serving)
+
+	atomic.StoreInt32(&fail, 1)
+	check(health.NotServing)
+
+	// the status is advertised in the registry
+	var md string
+	for i := 0; i < 10; i++ {
+		apps, err := prog.Options().Registry.Get("test.health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		md = apps[0].Instances[0].Metadata[health.MetadataKey]
+		if md == health.NotServing {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	if md != health.NotServing {
+		t.Fatalf("Expected advertised status %s got %q", health.NotServing, md)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c, err := config.Parse([]byte(`
+name: test.config
+version: 1.0.0
+client:
+  retries: 5
+server:
+  drain_timeout: 3s
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// options in code take precedence over config
+	prog := New(Config(c), Version("2.0.0"))
+
+	sopts := prog.Server().Options()
+	if sopts.Name != "test.config" {
+		t.Fatalf("Expected name test.config got %s", sopts.Name)
+	}
+	if sopts.Version != "2.0.0" {
+		t.Fatalf("Expected version 2.0.0 got %s", sopts.Version)
+	}
+	if sopts.DrainTimeout != time.Second*3 {
+		t.Fatalf("Expected drain timeout 3s got %v", sopts.DrainTimeout)
+	}
+	if r := prog.Client().Options().CallOptions.Retries; r != 5 {
+		t.Fatalf("Expected 5 retries got %d", r)
+	}
+
+	// options in code before config are overridden
+	prog = New(Version("2.0.0"), Config(c))
+	if v := prog.Server().Options().Version; v != "1.0.0" {
+		t.Fatalf("Expected version 1.0.0 got %s", v)
+	}
+}
+
+func TestHostConfig(t *testing.T) {
+	c, err := config.Parse([]byte(`
+name: test.host.config
+registry: memory
+broker: memory
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := NewHost(HostSignal(false))
+	prog := host.New(Config(c))
+
+	// the host components are shared whatever the config
+	if prog.Options().Registry != host.Options().Registry {
+		t.Fatal("Expected the registry of the host")
+	}
+	if prog.Options().Broker != host.Options().Broker {
+		t.Fatal("Expected the broker of the host")
+	}
+	if prog.Server().Options().Name != "test.host.config" {
+		t.Fatalf("Expected name test.host.config got %s", prog.Server().Options().Name)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	prog := testProgram(t, "test.func")
+	defer prog.Stop()
+
+	hello := func(ctx context.Context, req *TestRequest, rsp *TestResponse) error {
+		rsp.Message = "Hello " + req.Name
+		return nil
+	}
+
+	bye := func(ctx context.Context, req *TestRequest, rsp *TestResponse) error {
+		rsp.Message = "Bye " + req.Name
+		return nil
+	}
+
+	// funcs must be named
+	if err := prog.Register(hello); err == nil {
+		t.Fatal("Expected error registering func without an endpoint name")
+	}
+
+	// funcs can't be added to a struct handler
+	if err := prog.Register(hello, server.EndpointName("Test.Hello")); err == nil {
+		t.Fatal("Expected error registering func on a struct handler")
+	}
+
+	if err := prog.Register(hello, server.EndpointName("Greeter.Hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := prog.Register(bye, server.EndpointName("Greeter.Bye")); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := prog.Server().Options().Address
+
+	for ep, expect := range map[string]string{
+		"Greeter.Hello": "Hello John",
+		"Greeter.Bye":   "Bye John",
+	} {
+		var rsp TestResponse
+		if err := prog.ExecuteContext(context.Background(), "test.func", ep, &TestRequest{Name: "John"}, &rsp,
+			WithCallOptions(client.WithAddress(addr)),
+		); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Message != expect {
+			t.Fatalf("Expected %q got %q", expect, rsp.Message)
+		}
+	}
+
+	// the endpoints are advertised
+	if err := prog.Server().(registrar).Add(); err != nil {
+		t.Fatal(err)
+	}
+
+	apps, err := prog.Options().Registry.Get("test.func")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints := make(map[string]bool)
+	for _, ep := range apps[0].Endpoints {
+		endpoints[ep.Name] = true
+	}
+
+	if !endpoints["Greeter.Hello"] || !endpoints["Greeter.Bye"] {
+		t.Fatalf("Expected func endpoints to be advertised got %v", endpoints)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	prog := testProgram(t, "test.inspect")
+	defer prog.Stop()
+
+	app, err := prog.Inspect().App("test.inspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints := app.Versions[0].Endpoints
+	if len(endpoints) != 2 || endpoints[0].Name != "Test.Call" || endpoints[1].Name != "Test.Stream" || !endpoints[1].Stream {
+		t.Fatalf("Unexpected endpoints %+v", endpoints)
+	}
+
+	if req := endpoints[0].Request; req.Type != "TestRequest" || req.Values[0].Name != "Name" {
+		t.Fatalf("Unexpected request type %+v", req)
+	}
+}
@@ -3,6 +3,7 @@ package memory
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"time"
 
@@ -201,12 +202,13 @@ func (m *Table) Add(s *registry.App, opts ...registry.AddOption) error {
 
 	var addedInstances bool
 
-	for _, n := range s.Instances {
-		// check if already exists
-		if _, ok := srvs[s.Name][s.Version].Instances[n.Id]; ok {
-			continue
-		}
+	// update the endpoints if they changed e.g a handler was added
+	if existing := srvs[s.Name][s.Version]; !reflect.DeepEqual(existing.Endpoints, r.Endpoints) {
+		existing.Endpoints = r.Endpoints
+		addedInstances = true
+	}
 
+	for _, n := range s.Instances {
 		metadata := make(map[string]string)
 
 		// make copy of metadata
@@ -217,6 +219,20 @@ func (m *Table) Add(s *registry.App, opts ...registry.AddOption) error {
 		// set the domain
 		metadata["domain"] = options.Domain
 
+		// check if already exists
+		if existing, ok := srvs[s.Name][s.Version].Instances[n.Id]; ok {
+			// update the metadata if it changed e.g health status
+			if !reflect.DeepEqual(existing.Metadata, metadata) || existing.Address != n.Address {
+				existing.Instance = &registry.Instance{
+					Id:       n.Id,
+					Address:  n.Address,
+					Metadata: metadata,
+				}
+				addedInstances = true
+			}
+			continue
+		}
+
 		// add the node
 		srvs[s.Name][s.Version].Instances[n.Id] = &node{
 			Instance: &registry.Instance{
@@ -236,15 +252,15 @@ func (m *Table) Add(s *registry.App, opts ...registry.AddOption) error {
 			logger.Debugf("Table added new node to service: %s, version: %s", s.Name, s.Version)
 		}
 		go m.sendEvent(&registry.Result{Action: "update", App: s})
-	} else {
-		// refresh TTL and timestamp
-		for _, n := range s.Instances {
-			if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-				logger.Debugf("Updated registration for service: %s, version: %s", s.Name, s.Version)
-			}
-			srvs[s.Name][s.Version].Instances[n.Id].TTL = options.TTL
-			srvs[s.Name][s.Version].Instances[n.Id].LastSeen = time.Now()
+	}
+
+	// refresh TTL and timestamp
+	for _, n := range s.Instances {
+		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
+			logger.Debugf("Updated registration for service: %s, version: %s", s.Name, s.Version)
 		}
+		srvs[s.Name][s.Version].Instances[n.Id].TTL = options.TTL
+		srvs[s.Name][s.Version].Instances[n.Id].LastSeen = time.Now()
 	}
 
 	m.records[options.Domain] = srvs
@@ -341,12 +357,15 @@ func (m *Table) Get(name string, opts ...registry.GetOption) ([]*registry.App, e
 	// if it's a wildcard domain, return from all domains
 	if options.Domain == registry.GlobalDomain {
 		m.RLock()
-		recs := m.records
+		domains := make([]string, 0, len(m.records))
+		for domain := range m.records {
+			domains = append(domains, domain)
+		}
 		m.RUnlock()
 
 		var services []*registry.App
 
-		for domain := range recs {
+		for _, domain := range domains {
 			srvs, err := m.Get(name, append(opts, registry.GetDomain(domain))...)
 			if err == registry.ErrNotFound {
 				continue
@@ -403,12 +422,15 @@ func (m *Table) List(opts ...registry.ListOption) ([]*registry.App, error) {
 	// if it's a wildcard domain, list from all domains
 	if options.Domain == registry.GlobalDomain {
 		m.RLock()
-		recs := m.records
+		domains := make([]string, 0, len(m.records))
+		for domain := range m.records {
+			domains = append(domains, domain)
+		}
 		m.RUnlock()
 
 		var services []*registry.App
 
-		for domain := range recs {
+		for _, domain := range domains {
 			srvs, err := m.List(append(opts, registry.ListDomain(domain))...)
 			if err != nil {
 				return nil, err
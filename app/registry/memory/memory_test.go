@@ -193,6 +193,42 @@ func TestMemoryTableTTL(t *testing.T) {
 	}
 }
 
+func TestMemoryTableUpdateMetadata(t *testing.T) {
+	m := NewTable()
+
+	app := &registry.App{
+		Name:    "bar",
+		Version: "1.0.0",
+		Instances: []*registry.Instance{
+			{
+				Id:       "bar-1.0.0-123",
+				Address:  "localhost:9999",
+				Metadata: map[string]string{"health": "serving"},
+			},
+		},
+	}
+
+	if err := m.Add(app); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-register with changed metadata
+	app.Instances[0].Metadata = map[string]string{"health": "not_serving"}
+
+	if err := m.Add(app); err != nil {
+		t.Fatal(err)
+	}
+
+	svcs, err := m.Get("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := svcs[0].Instances[0].Metadata["health"]; v != "not_serving" {
+		t.Fatalf("Expected metadata to be updated to not_serving got %q", v)
+	}
+}
+
 func TestMemoryTableTTLConcurrent(t *testing.T) {
 	concurrency := 1000
 	waitTime := ttlPruneTime * 2
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/gonitro/nitro/app/router"
 )
@@ -17,11 +18,15 @@ func NewRouter(opts ...router.Option) router.Router {
 	if len(options.Network) == 0 {
 		options.Network = "micro"
 	}
-	return &dns{options}
+	return &dns{
+		options: options,
+		metrics: router.NewMetrics(),
+	}
 }
 
 type dns struct {
 	options router.Options
+	metrics *router.Metrics
 }
 
 func (d *dns) Init(opts ...router.Option) error {
@@ -63,6 +68,7 @@ func (d *dns) Lookup(service string, opts ...router.LookupOption) ([]router.Rout
 				Address: fmt.Sprintf("%s:%d", ip, uint16(p)),
 			}
 		}
+		d.metrics.Apply(result)
 		return result, nil
 	}
 
@@ -82,9 +88,29 @@ func (d *dns) Lookup(service string, opts ...router.LookupOption) ([]router.Rout
 			Network: d.options.Network,
 		}
 	}
+	d.metrics.Apply(result)
 	return result, nil
 }
 
+func (d *dns) Record(address string, latency time.Duration, err error) {
+	d.metrics.Record(address, latency, err)
+}
+
+// Metric returns the cost of an address
+func (d *dns) Metric(address string) int64 {
+	return d.metrics.Metric(address)
+}
+
+// Observe the latency of a successful call to an endpoint
+func (d *dns) Observe(endpoint string, latency time.Duration) {
+	d.metrics.Observe(endpoint, latency)
+}
+
+// Percentile returns the latency of the endpoint at the percentile
+func (d *dns) Percentile(endpoint string, p float64) (time.Duration, bool) {
+	return d.metrics.Percentile(endpoint, p)
+}
+
 func (d *dns) Watch(opts ...router.WatchOption) (router.Watcher, error) {
 	return nil, nil
 }
@@ -0,0 +1,175 @@
+package router
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultDecay is the weight given to the latest sample in the moving average
+	DefaultDecay = 0.3
+	// DefaultErrorCost is the cost added to a route for a 100% error rate
+	DefaultErrorCost = time.Second
+	// DefaultSamples is the number of recent latencies kept by endpoint
+	DefaultSamples = 100
+	// MinSamples is the number of latencies needed to estimate a percentile
+	MinSamples = 10
+	// DefaultMetricTTL is how long the metrics of an address without calls are kept
+	DefaultMetricTTL = time.Minute * 10
+)
+
+// Metrics tracks an exponentially weighted moving average of the latency
+// and error rate of calls to each address. The cost of an address is its
+// average latency in microseconds plus its error rate times the error cost.
+// The recent latencies of each endpoint are also kept to estimate percentiles.
+type Metrics struct {
+	sync.RWMutex
+	nodes map[string]*ewma
+	// latencies by endpoint
+	endpoints map[string]*samples
+	// last time the addresses without calls were deleted
+	pruned time.Time
+}
+
+type ewma struct {
+	latency float64
+	errors  float64
+	updated time.Time
+}
+
+// samples is a ring of the latest latencies
+type samples struct {
+	values []time.Duration
+	next   int
+}
+
+// NewMetrics returns a new set of metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		nodes:     make(map[string]*ewma),
+		endpoints: make(map[string]*samples),
+		pruned:    time.Now(),
+	}
+}
+
+// Record the latency and outcome of a call to an address
+func (m *Metrics) Record(address string, latency time.Duration, err error) {
+	sample := float64(latency / time.Microsecond)
+
+	var failed float64
+	if err != nil {
+		failed = 1
+	}
+
+	now := time.Now()
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.prune(now)
+
+	e, ok := m.nodes[address]
+	if !ok {
+		// seed the average with the first sample
+		m.nodes[address] = &ewma{latency: sample, errors: failed, updated: now}
+		return
+	}
+
+	e.latency = DefaultDecay*sample + (1-DefaultDecay)*e.latency
+	e.errors = DefaultDecay*failed + (1-DefaultDecay)*e.errors
+	e.updated = now
+}
+
+// prune deletes the addresses without calls for the metric ttl so addresses
+// which are gone aren't kept forever. It runs at most once per ttl. Called
+// under lock.
+func (m *Metrics) prune(now time.Time) {
+	if now.Sub(m.pruned) < DefaultMetricTTL {
+		return
+	}
+	m.pruned = now
+
+	for address, e := range m.nodes {
+		if now.Sub(e.updated) >= DefaultMetricTTL {
+			delete(m.nodes, address)
+		}
+	}
+}
+
+// Metric returns the cost of an address or DefaultMetric if nothing was recorded
+func (m *Metrics) Metric(address string) int64 {
+	m.RLock()
+	defer m.RUnlock()
+
+	e, ok := m.nodes[address]
+	if !ok {
+		return DefaultMetric
+	}
+
+	cost := int64(e.latency + e.errors*float64(DefaultErrorCost/time.Microsecond))
+	if cost < DefaultMetric {
+		return DefaultMetric
+	}
+	return cost
+}
+
+// Apply sets the metric of each route from the recorded calls
+func (m *Metrics) Apply(routes []Route) {
+	for i := range routes {
+		routes[i].Metric = m.Metric(routes[i].Address)
+	}
+}
+
+// Delete the metrics for an address
+func (m *Metrics) Delete(address string) {
+	m.Lock()
+	delete(m.nodes, address)
+	m.Unlock()
+}
+
+// Observe the latency of a successful call to an endpoint
+func (m *Metrics) Observe(endpoint string, latency time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	e, ok := m.endpoints[endpoint]
+	if !ok {
+		e = &samples{values: make([]time.Duration, 0, DefaultSamples)}
+		m.endpoints[endpoint] = e
+	}
+
+	if len(e.values) < cap(e.values) {
+		e.values = append(e.values, latency)
+		return
+	}
+
+	e.values[e.next] = latency
+	e.next = (e.next + 1) % len(e.values)
+}
+
+// Percentile returns the latency of the endpoint at the percentile e.g 0.95 for
+// the p95. False is returned until at least MinSamples latencies were observed.
+func (m *Metrics) Percentile(endpoint string, p float64) (time.Duration, bool) {
+	m.RLock()
+	e, ok := m.endpoints[endpoint]
+	if !ok || len(e.values) < MinSamples {
+		m.RUnlock()
+		return 0, false
+	}
+	values := make([]time.Duration, len(e.values))
+	copy(values, e.values)
+	m.RUnlock()
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	i := int(p*float64(len(values))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(values) {
+		i = len(values) - 1
+	}
+
+	return values[i], true
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+
+	if v := m.Metric("10.0.0.1:8080"); v != DefaultMetric {
+		t.Fatalf("Expected default metric got %d", v)
+	}
+
+	m.Record("10.0.0.1:8080", time.Millisecond, nil)
+	if v := m.Metric("10.0.0.1:8080"); v != 1000 {
+		t.Fatalf("Expected metric 1000 got %d", v)
+	}
+
+	// the average moves towards the latest sample
+	m.Record("10.0.0.1:8080", time.Millisecond*2, nil)
+	if v := m.Metric("10.0.0.1:8080"); v <= 1000 || v >= 2000 {
+		t.Fatalf("Expected metric between 1000 and 2000 got %d", v)
+	}
+
+	// errors cost more than latency
+	m.Record("10.0.0.2:8080", time.Millisecond, errors.New("failed"))
+
+	routes := []Route{{Address: "10.0.0.1:8080"}, {Address: "10.0.0.2:8080"}, {Address: "10.0.0.3:8080"}}
+	m.Apply(routes)
+
+	if routes[1].Metric <= routes[0].Metric {
+		t.Fatalf("Expected failing route to cost more got %d <= %d", routes[1].Metric, routes[0].Metric)
+	}
+	if routes[2].Metric != DefaultMetric {
+		t.Fatalf("Expected default metric got %d", routes[2].Metric)
+	}
+
+	m.Delete("10.0.0.2:8080")
+	if v := m.Metric("10.0.0.2:8080"); v != DefaultMetric {
+		t.Fatalf("Expected default metric after delete got %d", v)
+	}
+}
+
+func TestMetricsPrune(t *testing.T) {
+	ttl := DefaultMetricTTL
+	DefaultMetricTTL = time.Millisecond * 10
+	defer func() {
+		DefaultMetricTTL = ttl
+	}()
+
+	m := NewMetrics()
+	m.Record("10.0.0.1:8080", time.Millisecond, nil)
+
+	time.Sleep(time.Millisecond * 20)
+
+	// addresses without calls are deleted when the next call is recorded
+	m.Record("10.0.0.2:8080", time.Millisecond, nil)
+	if v := m.Metric("10.0.0.1:8080"); v != DefaultMetric {
+		t.Fatalf("Expected metric to be deleted got %d", v)
+	}
+	if v := m.Metric("10.0.0.2:8080"); v != 1000 {
+		t.Fatalf("Expected metric 1000 got %d", v)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	m := NewMetrics()
+
+	for i := 1; i < MinSamples; i++ {
+		m.Observe("greeter/Greeter.Hello", time.Millisecond)
+	}
+	if _, ok := m.Percentile("greeter/Greeter.Hello", 0.95); ok {
+		t.Fatal("Expected no percentile before the min samples")
+	}
+
+	// the window keeps the latest samples
+	for i := 1; i <= DefaultSamples; i++ {
+		m.Observe("greeter/Greeter.Hello", time.Duration(i)*time.Millisecond)
+	}
+
+	if d, _ := m.Percentile("greeter/Greeter.Hello", 0.95); d != time.Millisecond*95 {
+		t.Fatalf("Expected p95 of 95ms got %v", d)
+	}
+	if d, _ := m.Percentile("greeter/Greeter.Hello", 0.5); d != time.Millisecond*50 {
+		t.Fatalf("Expected p50 of 50ms got %v", d)
+	}
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	// the routes aren't ordered by cost
+	routes := []string{"10.0.0.3:8080", "10.0.0.1:8080", "10.0.0.2:8080"}
+	costs := map[string]int64{"10.0.0.1:8080": 1, "10.0.0.2:8080": 2, "10.0.0.3:8080": 3}
+
+	next, err := new(PowerOfTwo).Select(routes, SelectMetric(func(addr string) int64 {
+		return costs[addr]
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		counts[next()]++
+	}
+
+	// the most expensive route never wins a choice of two
+	if counts["10.0.0.3:8080"] > 0 {
+		t.Fatalf("Expected most expensive route not to be selected got %v", counts)
+	}
+	if counts["10.0.0.1:8080"] <= counts["10.0.0.2:8080"] {
+		t.Fatalf("Expected cheapest route to be selected most got %v", counts)
+	}
+
+	if _, err := new(PowerOfTwo).Select(nil); err != ErrNoneAvailable {
+		t.Fatalf("Expected none available got %v", err)
+	}
+}
@@ -24,6 +24,7 @@ type rtr struct {
 
 	running  bool
 	table    *table
+	metrics  *router.Metrics
 	options  router.Options
 	exit     chan bool
 	initChan chan bool
@@ -42,6 +43,7 @@ func NewRouter(opts ...router.Option) router.Router {
 	// construct the router
 	r := &rtr{
 		options:  options,
+		metrics:  router.NewMetrics(),
 		initChan: make(chan bool),
 	}
 
@@ -113,6 +115,7 @@ func (r *rtr) manageRoute(route router.Route, action string) error {
 		if err := r.table.Delete(route); err != nil && err != router.ErrRouteNotFound {
 			return fmt.Errorf("failed deleting route for service %s: %s", route.App, err)
 		}
+		r.metrics.Delete(route.Address)
 	case "update":
 		if err := r.table.Update(route); err != nil {
 			return fmt.Errorf("failed updating route for service %s: %s", route.App, err)
@@ -272,6 +275,7 @@ func (r *rtr) Lookup(service string, opts ...router.LookupOption) ([]router.Rout
 		if len(routes) == 0 {
 			return nil, router.ErrRouteNotFound
 		}
+		r.metrics.Apply(routes)
 		return routes, nil
 	}
 
@@ -305,9 +309,30 @@ func (r *rtr) Lookup(service string, opts ...router.LookupOption) ([]router.Rout
 	if len(routes) == 0 {
 		return nil, router.ErrRouteNotFound
 	}
+	r.metrics.Apply(routes)
 	return routes, nil
 }
 
+// Record the latency and outcome of a call so it's reflected in the route metric
+func (r *rtr) Record(address string, latency time.Duration, err error) {
+	r.metrics.Record(address, latency, err)
+}
+
+// Metric returns the cost of an address
+func (r *rtr) Metric(address string) int64 {
+	return r.metrics.Metric(address)
+}
+
+// Observe the latency of a successful call to an endpoint
+func (r *rtr) Observe(endpoint string, latency time.Duration) {
+	r.metrics.Observe(endpoint, latency)
+}
+
+// Percentile returns the latency of the endpoint at the percentile
+func (r *rtr) Percentile(endpoint string, p float64) (time.Duration, bool) {
+	return r.metrics.Percentile(endpoint, p)
+}
+
 // watchRegistry watches registry and updates routing table based on the received events.
 // It returns error if either the registry watcher fails with error or if the routing table update fails.
 func (r *rtr) watchRegistry(w registry.Watcher) error {
@@ -4,6 +4,7 @@ package router
 import (
 	"errors"
 	"hash/fnv"
+	"time"
 )
 
 var (
@@ -37,6 +38,22 @@ type Router interface {
 	String() string
 }
 
+// Recorder is implemented by routers which reflect the outcome of calls in the route metric
+type Recorder interface {
+	// Record the latency and outcome of a call to an address
+	Record(address string, latency time.Duration, err error)
+	// Metric returns the cost of an address
+	Metric(address string) int64
+}
+
+// Latencies is implemented by routers which track the latency of calls by endpoint
+type Latencies interface {
+	// Observe the latency of a successful call to an endpoint
+	Observe(endpoint string, latency time.Duration)
+	// Percentile returns the latency of the endpoint at the percentile e.g 0.95
+	Percentile(endpoint string, p float64) (time.Duration, bool)
+}
+
 // Table is an interface for routing table
 type Table interface {
 	// Create new route in the routing table
@@ -16,10 +16,20 @@ type Selector interface {
 	Select([]string, ...SelectOption) (Next, error)
 }
 
-type SelectorOptions struct{}
+type SelectorOptions struct {
+	// Metric returns the cost of an address
+	Metric func(address string) int64
+}
 
 type SelectOption func(o *SelectorOptions)
 
+// SelectMetric sets the func which returns the cost of an address
+func SelectMetric(fn func(address string) int64) SelectOption {
+	return func(o *SelectorOptions) {
+		o.Metric = fn
+	}
+}
+
 // Next returns the next node
 type Next func() string
 
@@ -59,3 +69,42 @@ func (r *RoundRobin) Select(routes []string, opts ...SelectOption) (Next, error)
 		return route
 	}, nil
 }
+
+// PowerOfTwo picks two routes at random and selects the one with the lower
+// metric as returned by the SelectMetric option. Slower routes still receive
+// some traffic so their metric stays current. Without a metric it's random.
+type PowerOfTwo struct{}
+
+func (p *PowerOfTwo) Select(routes []string, opts ...SelectOption) (Next, error) {
+	if len(routes) == 0 {
+		return nil, ErrNoneAvailable
+	}
+
+	var options SelectorOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	metric := options.Metric
+	if metric == nil {
+		metric = func(string) int64 { return DefaultMetric }
+	}
+
+	return func() string {
+		if len(routes) == 1 {
+			return routes[0]
+		}
+
+		i := rand.Intn(len(routes))
+		j := rand.Intn(len(routes) - 1)
+		// pick a distinct second route
+		if j >= i {
+			j++
+		}
+
+		if metric(routes[j]) < metric(routes[i]) {
+			return routes[j]
+		}
+		return routes[i]
+	}, nil
+}
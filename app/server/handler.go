@@ -7,6 +7,8 @@ type HandlerOption func(*HandlerOptions)
 type HandlerOptions struct {
 	Internal bool
 	Metadata map[string]map[string]string
+	// Endpoint is the name of a func handler e.g Greeter.Hello
+	Endpoint string
 }
 
 type SubscriberOption func(*SubscriberOptions)
@@ -28,6 +30,14 @@ func EndpointMetadata(name string, md map[string]string) HandlerOption {
 	}
 }
 
+// EndpointName sets the endpoint name of a handler which is a func rather
+// than a struct. The name is of the form Handler.Method e.g Greeter.Hello
+func EndpointName(name string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Endpoint = name
+	}
+}
+
 // Internal Handler options specifies that a handler is not advertised
 // to the discovery system. In the future this may also limit request
 // to the internal network or authorised user.
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/gonitro/nitro/app/network"
+)
+
+// ErrNotLocal is returned when a request can't be served in process
+var ErrNotLocal = errors.New("not served locally")
+
+// Local is a server which serves unary requests in process without the network.
+// The header holds the metadata of the request including the App and Endpoint.
+// Response headers such as the RetryAfterHeader of a shed request are set in it.
+// ErrNotLocal is returned if the request can only be served over the network.
+type Local interface {
+	ServeLocal(ctx context.Context, header map[string]string, req, rsp interface{}) error
+}
+
+// localKey is an address of a transport. Servers are keyed by their transport
+// so they're only reachable in process by clients which could dial them.
+type localKey struct {
+	transport network.Transport
+	address   string
+}
+
+var locals = struct {
+	sync.RWMutex
+	servers map[localKey]Local
+}{
+	servers: make(map[localKey]Local),
+}
+
+// AddLocal makes the server reachable in process at the addresses of the transport
+func AddLocal(t network.Transport, s Local, addrs ...string) {
+	locals.Lock()
+	defer locals.Unlock()
+
+	for _, addr := range addrs {
+		if len(addr) == 0 || strings.HasSuffix(addr, ":0") {
+			continue
+		}
+		locals.servers[localKey{t, addr}] = s
+	}
+}
+
+// RemoveLocal removes every address the server is reachable at
+func RemoveLocal(s Local) {
+	locals.Lock()
+	defer locals.Unlock()
+
+	for k, srv := range locals.servers {
+		if srv == s {
+			delete(locals.servers, k)
+		}
+	}
+}
+
+// LookupLocal returns the server in this process listening at the address of the transport
+func LookupLocal(t network.Transport, addr string) (Local, bool) {
+	locals.RLock()
+	defer locals.RUnlock()
+
+	s, ok := locals.servers[localKey{t, addr}]
+	return s, ok
+}
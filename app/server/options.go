@@ -21,6 +21,7 @@ type Options struct {
 	Broker       event.Broker
 	Registry     registry.Table
 	Auth         crypto.Auth
+	Rules        crypto.Rules
 	Transport    network.Transport
 	Metadata     map[string]string
 	Name         string
@@ -38,6 +39,18 @@ type Options struct {
 	AddTTL time.Duration
 	// The interval on which to register
 	AddInterval time.Duration
+	// DrainTimeout is the max time to wait for in-flight requests on stop
+	DrainTimeout time.Duration
+	// StreamWrappers wrap the stream of each streaming request
+	StreamWrappers []StreamWrapper
+	// MaxConcurrency is the max number of requests executed at once. Zero is unlimited.
+	MaxConcurrency int
+	// EndpointConcurrency is the max number of requests executed at once by endpoint
+	EndpointConcurrency map[string]int
+	// QueueWait is the max time a request waits to be executed before it's shed
+	QueueWait time.Duration
+	// RetryAfter is the time a client is asked to wait before retrying a shed request
+	RetryAfter time.Duration
 
 	// The router for requests
 	Router Router
@@ -52,10 +65,13 @@ type Options struct {
 
 func newOptions(opt ...Option) Options {
 	opts := Options{
-		Codecs:      make(map[string]codec.NewCodec),
-		Metadata:    map[string]string{},
-		AddInterval: DefaultAddInterval,
-		AddTTL:      DefaultAddTTL,
+		Codecs:              make(map[string]codec.NewCodec),
+		Metadata:            map[string]string{},
+		AddInterval:         DefaultAddInterval,
+		AddTTL:              DefaultAddTTL,
+		DrainTimeout:        DefaultDrainTimeout,
+		EndpointConcurrency: map[string]int{},
+		RetryAfter:          DefaultRetryAfter,
 	}
 
 	for _, o := range opt {
@@ -176,6 +192,14 @@ func Auth(a crypto.Auth) Option {
 	}
 }
 
+// Rules verify the account of a request has access to the endpoint. The
+// resource is of type app named after the server with the endpoint called.
+func Rules(r crypto.Rules) Option {
+	return func(o *Options) {
+		o.Rules = r
+	}
+}
+
 // Transport mechanism for communication e.g http, rabbitmq, etc
 func Transport(t network.Transport) Option {
 	return func(o *Options) {
@@ -211,6 +235,47 @@ func AddInterval(t time.Duration) Option {
 	}
 }
 
+// DrainTimeout sets the max time to wait for in-flight
+// requests to finish when the server is stopped. Zero waits forever.
+func DrainTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.DrainTimeout = t
+	}
+}
+
+// MaxConcurrency sets the max number of requests executed at once. Requests
+// over the limit wait up to the QueueWait and are then shed.
+func MaxConcurrency(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrency = n
+	}
+}
+
+// EndpointConcurrency sets the max number of requests executed at once by an endpoint
+func EndpointConcurrency(endpoint string, n int) Option {
+	return func(o *Options) {
+		if o.EndpointConcurrency == nil {
+			o.EndpointConcurrency = make(map[string]int)
+		}
+		o.EndpointConcurrency[endpoint] = n
+	}
+}
+
+// QueueWait sets the max time a request waits to be executed before
+// it's shed. Zero sheds requests over the limit immediately.
+func QueueWait(d time.Duration) Option {
+	return func(o *Options) {
+		o.QueueWait = d
+	}
+}
+
+// RetryAfter sets the time a client is asked to wait before retrying a shed request
+func RetryAfter(d time.Duration) Option {
+	return func(o *Options) {
+		o.RetryAfter = d
+	}
+}
+
 // TLSConfig specifies a *tls.Config
 func TLSConfig(t *tls.Config) Option {
 	return func(o *Options) {
@@ -238,10 +303,10 @@ func WithRouter(r Router) Option {
 	}
 }
 
-// Wait tells the server to wait for requests to finish before exiting
-// If `wg` is nil, server only wait for completion of rpc handler.
-// For user need finer grained control, pass a concrete `wg` here, server will
-// wait against it on stop.
+// Wait tells the server to wait for requests to finish before exiting.
+// The server always drains its requests on stop up to the DrainTimeout.
+// For finer grained control pass a concrete `wg` here, each request is
+// added to it so the caller can wait against it after stop.
 func Wait(wg *sync.WaitGroup) Option {
 	return func(o *Options) {
 		if o.Context == nil {
@@ -261,6 +326,13 @@ func WrapHandler(w HandlerWrapper) Option {
 	}
 }
 
+// Adds a stream Wrapper to a list of options passed into the server
+func WrapStream(w StreamWrapper) Option {
+	return func(o *Options) {
+		o.StreamWrappers = append(o.StreamWrappers, w)
+	}
+}
+
 // Adds a subscriber Wrapper to a list of options passed into the server
 func WrapSubscriber(w SubscriberWrapper) Option {
 	return func(o *Options) {
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gonitro/nitro/app/crypto"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/server"
+)
+
+// BearerScheme is the prefix of the Authorization header holding a token
+const BearerScheme = "Bearer "
+
+// authWrapper inspects the bearer token of a request and verifies the account
+// has access to the endpoint. The account is set in the context of the handler.
+func authWrapper(opts server.Options) server.HandlerWrapper {
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			var acc *crypto.Account
+
+			if token := bearerToken(req.Header()); len(token) > 0 && opts.Auth != nil {
+				a, err := opts.Auth.Inspect(token)
+				if err != nil {
+					return errors.Unauthorized(opts.Name, "invalid token")
+				}
+				acc = a
+				ctx = crypto.ContextWithAccount(ctx, acc)
+			}
+
+			if opts.Rules == nil {
+				return fn(ctx, req, rsp)
+			}
+
+			res := &crypto.Resource{
+				Type:     "app",
+				Name:     opts.Name,
+				Endpoint: req.Endpoint(),
+			}
+
+			if err := opts.Rules.Verify(acc, res); err == crypto.ErrForbidden && acc == nil {
+				return errors.Unauthorized(opts.Name, "unauthorized request to %s", req.Endpoint())
+			} else if err != nil {
+				return errors.Forbidden(opts.Name, "forbidden request to %s", req.Endpoint())
+			}
+
+			return fn(ctx, req, rsp)
+		}
+	}
+}
+
+// bearerToken returns the token of the Authorization header
+func bearerToken(hdr map[string]string) string {
+	auth := getHeader("Authorization", hdr)
+	if !strings.HasPrefix(auth, BearerScheme) {
+		return ""
+	}
+	return strings.TrimPrefix(auth, BearerScheme)
+}
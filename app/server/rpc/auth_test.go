@@ -0,0 +1,94 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gonitro/nitro/app/crypto"
+	"github.com/gonitro/nitro/app/crypto/memory"
+	"github.com/gonitro/nitro/app/crypto/noop"
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/server"
+)
+
+// testAuth returns an account with the token as its scope
+type testAuth struct {
+	crypto.Auth
+}
+
+func (a *testAuth) Inspect(token string) (*crypto.Account, error) {
+	if token == "invalid" {
+		return nil, crypto.ErrInvalidToken
+	}
+	return &crypto.Account{ID: token, Scopes: []string{token}}, nil
+}
+
+type Secure struct{}
+
+func (s *Secure) Greet(ctx context.Context, req *LocalRequest, rsp *LocalResponse) error {
+	acc, _ := crypto.AccountFromContext(ctx)
+	rsp.Greeting = "Hello " + acc.ID
+	return nil
+}
+
+func TestAuth(t *testing.T) {
+	rules := memory.NewRules(&crypto.Rule{
+		ID:       "admin",
+		Scope:    "admin",
+		Resource: &crypto.Resource{Type: "app", Name: "secure", Endpoint: "Secure.Greet"},
+	})
+
+	s := NewServer(
+		server.Name("secure"),
+		server.Address("127.0.0.1:0"),
+		server.Auth(&testAuth{Auth: noop.NewAuth()}),
+		server.Rules(rules),
+	)
+
+	if err := s.Handle(s.NewHandler(new(Secure))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	addr := s.Options().Address
+
+	testCases := []struct {
+		token string
+		code  int32
+	}{
+		{"", 401},
+		{"invalid", 401},
+		{"user", 403},
+		{"admin", 0},
+	}
+
+	for _, tc := range testCases {
+		hdr := map[string]string{
+			"App":      "secure",
+			"Endpoint": "Secure.Greet",
+		}
+		if len(tc.token) > 0 {
+			hdr["Authorization"] = BearerScheme + tc.token
+		}
+
+		rsp := new(LocalResponse)
+		err := callLocal(context.TODO(), s.Options().Transport, addr, hdr, &LocalRequest{}, rsp)
+
+		if tc.code == 0 {
+			if err != nil {
+				t.Fatalf("Expected token %q to be granted access got %v", tc.token, err)
+			}
+			if rsp.Greeting != "Hello admin" {
+				t.Fatalf("Expected the account in the context got %q", rsp.Greeting)
+			}
+			continue
+		}
+
+		if code := errors.FromError(err).Code; code != tc.code {
+			t.Fatalf("Expected token %q to return %d got %v", tc.token, tc.code, err)
+		}
+	}
+}
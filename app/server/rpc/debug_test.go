@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/client"
+	rpcClient "github.com/gonitro/nitro/app/client/rpc"
+	"github.com/gonitro/nitro/app/debug"
+	"github.com/gonitro/nitro/app/logger"
+	"github.com/gonitro/nitro/app/network/socket"
+	"github.com/gonitro/nitro/app/server"
+)
+
+func TestDebugHandler(t *testing.T) {
+	s := NewServer(
+		server.Name("debug"),
+		server.Address("127.0.0.1:0"),
+		server.MaxConcurrency(4),
+	)
+
+	if err := s.Handle(s.NewHandler(new(Local))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	addr := s.Options().Address
+	call := func(endpoint string, req, rsp interface{}) error {
+		hdr := map[string]string{"App": "debug", "Endpoint": endpoint}
+		return callLocal(context.TODO(), s.Options().Transport, addr, hdr, req, rsp)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := call("Local.Greet", &LocalRequest{Name: "John", Tags: []string{"a"}}, new(LocalResponse)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := new(debug.StatsResponse)
+	if err := call("Debug.Stats", new(debug.Request), stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Goroutines == 0 || stats.Started == 0 {
+		t.Fatalf("Expected runtime stats got %+v", stats)
+	}
+	if len(stats.Endpoints) == 0 || stats.Endpoints[0].Name != "Local.Greet" || stats.Endpoints[0].Requests != 2 {
+		t.Fatalf("Expected 2 requests to Local.Greet got %+v", stats.Endpoints)
+	}
+
+	hdlrs := new(debug.HandlersResponse)
+	if err := call("Debug.Handlers", new(debug.Request), hdlrs); err != nil {
+		t.Fatal(err)
+	}
+	if len(hdlrs.Handlers) != 2 {
+		t.Fatalf("Expected 2 handlers got %d", len(hdlrs.Handlers))
+	}
+	if h := hdlrs.Handlers[0]; h.Name != "Debug" || !h.Internal {
+		t.Fatalf("Expected internal Debug handler got %+v", h)
+	}
+	if h := hdlrs.Handlers[1]; h.Name != "Local" || h.Internal || len(h.Endpoints) != 1 {
+		t.Fatalf("Expected Local handler got %+v", h)
+	}
+
+	opts := new(debug.OptionsResponse)
+	if err := call("Debug.Options", new(debug.Request), opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Name != "debug" || opts.Address != addr || opts.MaxConcurrency != 4 {
+		t.Fatalf("Expected server options got %+v", opts)
+	}
+
+	// log lines are kept for the Log stream
+	logs := debug.Logs()
+	logger.Info("debug handler test")
+
+	entries := logs.Get(1)
+	if len(entries) != 1 {
+		t.Fatal("Expected a log entry")
+	}
+	if msg, _ := entries[0].Value.(string); !strings.Contains(msg, "debug handler test") {
+		t.Fatalf("Expected log message got %v", entries[0].Value)
+	}
+}
+
+func TestDebugLog(t *testing.T) {
+	tr := socket.NewTransport()
+
+	s := NewServer(
+		server.Name("debug"),
+		server.Address("127.0.0.1:0"),
+		server.Transport(tr),
+	)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	logger.Info("debug log before stream")
+
+	c := rpcClient.NewClient(client.Transport(tr), client.Local(false))
+	req := c.NewRequest("debug", "Debug.Log", new(debug.Request))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	stream, err := c.Stream(ctx, req, client.WithAddress(s.Options().Address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	seen := make(map[debug.Record]int)
+
+	// receive records until the message is seen
+	recv := func(msg string) {
+		for {
+			rec := new(debug.Record)
+			if err := stream.Recv(rec); err != nil {
+				t.Fatalf("Expected %q got %v", msg, err)
+			}
+			seen[*rec]++
+			if strings.Contains(rec.Message, msg) {
+				return
+			}
+		}
+	}
+
+	recv("debug log before stream")
+
+	// new entries are streamed even once the default logger is replaced
+	dl := logger.DefaultLogger
+	defer func() {
+		logger.DefaultLogger = dl
+	}()
+	logger.DefaultLogger = logger.NewHelper(logger.NewLogger(logger.WithOutput(ioutil.Discard)))
+
+	logger.Info("debug log after stream")
+	recv("debug log after stream")
+
+	// entries logged by other apps in the process are skipped
+	logger.Fields(map[string]interface{}{debug.AppField: "other"}).Log(logger.InfoLevel, "other app entry")
+	logger.Info("debug log after other app")
+	recv("debug log after other app")
+
+	for rec, n := range seen {
+		if strings.Contains(rec.Message, "other app entry") {
+			t.Fatalf("Expected entries of other apps to be skipped got %q", rec.Message)
+		}
+		if n > 1 {
+			t.Fatalf("Expected log entry to be sent once got %d of %q", n, rec.Message)
+		}
+	}
+}
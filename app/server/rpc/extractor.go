@@ -34,18 +34,18 @@ func extractValue(v reflect.Type, d int) *registry.Value {
 				continue
 			}
 
+			// the value is named by the field not its type
+			val.Name = f.Name
+
 			// if we can find a json tag use it
 			if tags := f.Tag.Get("json"); len(tags) > 0 {
 				parts := strings.Split(tags, ",")
-				if parts[0] == "-" || parts[0] == "omitempty" {
+				if parts[0] == "-" {
 					continue
 				}
-				val.Name = parts[0]
-			}
-
-			// if there's no name default it
-			if len(val.Name) == 0 {
-				val.Name = v.Field(i).Name
+				if len(parts[0]) > 0 {
+					val.Name = parts[0]
+				}
 			}
 
 			// still no name then continue
@@ -72,7 +72,6 @@ func extractEndpoint(method reflect.Method) *registry.Endpoint {
 	}
 
 	var rspType, reqType reflect.Type
-	var stream bool
 	mt := method.Type
 
 	switch mt.NumIn() {
@@ -86,6 +85,30 @@ func extractEndpoint(method reflect.Method) *registry.Endpoint {
 		return nil
 	}
 
+	return newEndpoint(method.Name, reqType, rspType)
+}
+
+// extractFuncEndpoint extracts the endpoint of a func handler
+func extractFuncEndpoint(name string, typ reflect.Type) *registry.Endpoint {
+	var rspType, reqType reflect.Type
+
+	switch typ.NumIn() {
+	case 2:
+		reqType = typ.In(0)
+		rspType = typ.In(1)
+	case 3:
+		reqType = typ.In(1)
+		rspType = typ.In(2)
+	default:
+		return nil
+	}
+
+	return newEndpoint(name, reqType, rspType)
+}
+
+func newEndpoint(name string, reqType, rspType reflect.Type) *registry.Endpoint {
+	var stream bool
+
 	// are we dealing with a stream?
 	switch rspType.Kind() {
 	case reflect.Func, reflect.Interface:
@@ -96,7 +119,7 @@ func extractEndpoint(method reflect.Method) *registry.Endpoint {
 	response := extractValue(rspType, 0)
 
 	ep := &registry.Endpoint{
-		Name:     method.Name,
+		Name:     name,
 		Request:  request,
 		Response: response,
 		Metadata: make(map[string]string),
@@ -63,3 +63,24 @@ func TestExtractEndpoint(t *testing.T) {
 	}
 
 }
+
+type testFields struct {
+	Name  string
+	Email string `json:"email,omitempty"`
+	Count int    `json:",omitempty"`
+	Skip  string `json:"-"`
+}
+
+func TestExtractValueFieldNames(t *testing.T) {
+	v := extractValue(reflect.TypeOf(&testFields{}), 0)
+
+	var names []string
+	for _, f := range v.Values {
+		names = append(names, f.Name+" "+f.Type)
+	}
+
+	expect := []string{"Name string", "email string", "Count int"}
+	if !reflect.DeepEqual(names, expect) {
+		t.Fatalf("Expected fields %v got %v", expect, names)
+	}
+}
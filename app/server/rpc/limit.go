@@ -0,0 +1,185 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/server"
+)
+
+// limits caps the number of requests executed at once by the
+// server and by endpoint. Requests over a limit are queued for
+// up to the wait and are then shed.
+type limits struct {
+	name string
+	wait time.Duration
+	// options the semaphores were created with
+	max         int
+	concurrency map[string]int
+	// global semaphore, nil if unlimited
+	global chan bool
+	// semaphore for each limited endpoint
+	endpoints map[string]chan bool
+}
+
+func newLimits(opts server.Options) *limits {
+	l := &limits{
+		name:        opts.Name,
+		wait:        opts.QueueWait,
+		max:         opts.MaxConcurrency,
+		concurrency: make(map[string]int),
+		endpoints:   make(map[string]chan bool),
+	}
+
+	if opts.MaxConcurrency > 0 {
+		l.global = make(chan bool, opts.MaxConcurrency)
+	}
+
+	for endpoint, n := range opts.EndpointConcurrency {
+		if n > 0 {
+			l.concurrency[endpoint] = n
+			l.endpoints[endpoint] = make(chan bool, n)
+		}
+	}
+
+	return l
+}
+
+// changed returns true if the limits set in the options differ. The limits
+// are only replaced when changed so requests in flight keep holding their slots.
+func (l *limits) changed(opts server.Options) bool {
+	if l.name != opts.Name || l.wait != opts.QueueWait || l.max != opts.MaxConcurrency {
+		return true
+	}
+
+	n := 0
+	for endpoint, c := range opts.EndpointConcurrency {
+		if c <= 0 {
+			continue
+		}
+		if l.concurrency[endpoint] != c {
+			return true
+		}
+		n++
+	}
+
+	return n != len(l.concurrency)
+}
+
+// queues returns true if requests over a limit wait for a slot
+func (l *limits) queues() bool {
+	return l.wait > 0
+}
+
+// semaphores returns the semaphores limiting the endpoint. The endpoint
+// comes first so a request queued for a busy endpoint doesn't hold one
+// of the global slots.
+func (l *limits) semaphores(endpoint string) []chan bool {
+	sems := make([]chan bool, 0, 2)
+	if sem, ok := l.endpoints[endpoint]; ok {
+		sems = append(sems, sem)
+	}
+	if l.global != nil {
+		sems = append(sems, l.global)
+	}
+	return sems
+}
+
+// shed returns the error for a request shed at the limit of the endpoint
+func (l *limits) shed(endpoint string) error {
+	return errors.AppUnavailable(l.name, "too many requests to %s", endpoint)
+}
+
+// tryAcquire takes a slot to execute a request to the endpoint without waiting.
+// The release func must be called when the request is done. False is returned
+// if the server or endpoint is at its limit.
+func (l *limits) tryAcquire(endpoint string) (func(), bool) {
+	sems := l.semaphores(endpoint)
+
+	for i, sem := range sems {
+		select {
+		case sem <- true:
+		default:
+			// give back the slots already taken
+			for _, s := range sems[:i] {
+				<-s
+			}
+			return nil, false
+		}
+	}
+
+	return func() {
+		for _, sem := range sems {
+			<-sem
+		}
+	}, true
+}
+
+// acquire a slot to execute a request to the endpoint waiting up to the queue wait.
+// The release func must be called when the request is done.
+func (l *limits) acquire(ctx context.Context, endpoint string) (func(), error) {
+	sems := l.semaphores(endpoint)
+
+	release := func() {
+		for _, sem := range sems {
+			<-sem
+		}
+	}
+
+	if len(sems) == 0 {
+		return release, nil
+	}
+
+	var timeout <-chan time.Time
+	if l.wait > 0 {
+		t := time.NewTimer(l.wait)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	for i, sem := range sems {
+		if err := l.take(ctx, sem, timeout); err != nil {
+			// give back the slots already taken
+			for _, s := range sems[:i] {
+				<-s
+			}
+			return nil, l.shed(endpoint)
+		}
+	}
+
+	return release, nil
+}
+
+// take a slot of the semaphore waiting until the timeout
+func (l *limits) take(ctx context.Context, sem chan bool, timeout <-chan time.Time) error {
+	select {
+	case sem <- true:
+		return nil
+	default:
+	}
+
+	// shed immediately if there's no queueing
+	if timeout == nil {
+		return context.DeadlineExceeded
+	}
+
+	select {
+	case sem <- true:
+		return nil
+	case <-timeout:
+		return context.DeadlineExceeded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryAfter returns a copy of the header with the time to wait before retrying a shed request
+func retryAfter(header map[string]string, d time.Duration) map[string]string {
+	hdr := make(map[string]string, len(header)+1)
+	for k, v := range header {
+		hdr[k] = v
+	}
+	hdr[server.RetryAfterHeader] = d.String()
+	return hdr
+}
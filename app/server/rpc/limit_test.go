@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/server"
+)
+
+func TestLimits(t *testing.T) {
+	l := newLimits(server.Options{
+		Name:                "test",
+		MaxConcurrency:      2,
+		EndpointConcurrency: map[string]int{"Test.Slow": 1},
+		QueueWait:           time.Millisecond * 20,
+	})
+
+	release, err := l.acquire(context.TODO(), "Test.Slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the endpoint is at its limit so the request waits then is shed
+	start := time.Now()
+	_, err = l.acquire(context.TODO(), "Test.Slow")
+	if errors.FromError(err).Code != 503 {
+		t.Fatalf("Expected request to be shed got %v", err)
+	}
+	if d := time.Since(start); d < time.Millisecond*20 {
+		t.Fatalf("Expected request to queue took %v", d)
+	}
+
+	// other endpoints use the global limit
+	fast, err := l.acquire(context.TODO(), "Test.Fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire(context.TODO(), "Test.Fast"); err == nil {
+		t.Fatal("Expected global limit to be reached")
+	}
+
+	// queued requests run once a slot is released
+	go func() {
+		time.Sleep(time.Millisecond * 5)
+		fast()
+	}()
+
+	next, err := l.acquire(context.TODO(), "Test.Fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release()
+	next()
+}
+
+func TestLimitsInit(t *testing.T) {
+	s := newServer(
+		server.Name("test"),
+		server.MaxConcurrency(1),
+	).(*rpcServer)
+
+	release, ok := s.limits.tryAcquire("Test.Call")
+	if !ok {
+		t.Fatal("Expected a slot to be free")
+	}
+	defer release()
+
+	// changing other options keeps the slots taken
+	if err := s.Init(server.Metadata(map[string]string{"foo": "bar"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.limits.tryAcquire("Test.Call"); ok {
+		t.Fatal("Expected the limit to be kept after init")
+	}
+
+	// changing the limits replaces them
+	if err := s.Init(server.MaxConcurrency(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.limits.tryAcquire("Test.Call"); !ok {
+		t.Fatal("Expected the limits to be replaced")
+	}
+}
@@ -0,0 +1,237 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gonitro/nitro/app/codec"
+	merrors "github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/server"
+)
+
+// ErrNotLocal is returned when a request can't be served in process
+var ErrNotLocal = server.ErrNotLocal
+
+// ServeLocal executes a unary request in process. The handler is invoked directly
+// without a network and the request and response are copied through the codec of
+// the content type to preserve isolation. ErrNotLocal is returned if the request
+// can only be served remotely. The RetryAfterHeader is set in the header if the
+// request is shed.
+func (s *rpcServer) ServeLocal(ctx context.Context, header map[string]string, req, rsp interface{}) error {
+	s.RLock()
+	rtr := s.router
+	opts := s.opts
+	lims := s.limits
+	s.RUnlock()
+
+	// a custom router may proxy requests so send them over the network
+	if opts.Router != nil {
+		return ErrNotLocal
+	}
+
+	ct := header["Content-Type"]
+	if len(ct) == 0 {
+		ct = DefaultContentType
+	}
+
+	cf, err := s.newCodec(ct)
+	if err != nil {
+		return ErrNotLocal
+	}
+
+	// track the request so we can drain on stop
+	done, ok := s.reqs.add()
+	if !ok {
+		return errStopping(opts.Name)
+	}
+	defer done()
+
+	// copy the headers
+	hdr := make(map[string]string, len(header))
+	for k, v := range header {
+		hdr[k] = v
+	}
+
+	// set local/remote addresses
+	hdr["Local"] = opts.Address
+	hdr["Remote"] = opts.Address
+
+	endpoint := getHeader("Endpoint", hdr)
+
+	// wait to be executed or shed the request
+	release, err := lims.acquire(ctx, endpoint)
+	if err != nil {
+		// ask the client to back off before retrying
+		header[server.RetryAfterHeader] = opts.RetryAfter.String()
+		return err
+	}
+	defer release()
+
+	request := &rpcRequest{
+		service:     getHeader("App", hdr),
+		method:      getHeader("Method", hdr),
+		endpoint:    endpoint,
+		contentType: ct,
+		header:      hdr,
+	}
+
+	return rtr.serveLocal(metadata.NewContext(ctx, hdr), request, cf, req, rsp)
+}
+
+// ServeHandler calls an endpoint of the handler directly with the decoded request
+// and response as a server would, including the metadata of the context. It's used
+// to test handlers without a server or network. Streaming endpoints aren't supported.
+func ServeHandler(ctx context.Context, h server.Handler, endpoint string, req, rsp interface{}) error {
+	rtr := newRpcRouter()
+	if err := rtr.Handle(h); err != nil {
+		return err
+	}
+
+	hdr := make(map[string]string)
+	if md, ok := metadata.FromContext(ctx); ok {
+		for k, v := range md {
+			hdr[k] = v
+		}
+	}
+
+	ct := hdr["Content-Type"]
+	if len(ct) == 0 {
+		ct = DefaultContentType
+	}
+
+	cf, ok := DefaultCodecs[ct]
+	if !ok {
+		return merrors.BadRequest("nitro", "unsupported content type %s", ct)
+	}
+
+	request := &rpcRequest{
+		service:     h.Name(),
+		method:      endpoint,
+		endpoint:    endpoint,
+		contentType: ct,
+		header:      hdr,
+	}
+
+	err := rtr.serveLocal(metadata.NewContext(ctx, hdr), request, cf, req, rsp)
+	if err == ErrNotLocal {
+		return merrors.BadRequest("nitro", "can't serve %s with request %T and response %T", endpoint, req, rsp)
+	}
+
+	return err
+}
+
+// serveLocal looks up the handler for the request and calls it directly
+func (router *router) serveLocal(ctx context.Context, r *rpcRequest, cf codec.NewCodec, req, rsp interface{}) (err error) {
+	serviceMethod := strings.Split(r.endpoint, ".")
+	if len(serviceMethod) != 2 {
+		return ErrNotLocal
+	}
+
+	router.mu.Lock()
+	service := router.serviceMap[serviceMethod[0]]
+	router.mu.Unlock()
+	if service == nil {
+		return ErrNotLocal
+	}
+
+	mtype := service.method[serviceMethod[1]]
+	if mtype == nil || mtype.stream {
+		return ErrNotLocal
+	}
+
+	// decode the request into a new value of the argument type
+	var argv reflect.Value
+	argIsValue := false
+	if mtype.ArgType.Kind() == reflect.Ptr {
+		argv = reflect.New(mtype.ArgType.Elem())
+	} else {
+		argv = reflect.New(mtype.ArgType)
+		argIsValue = true
+	}
+
+	// a request which can't be decoded is left to fail over the network
+	if err := roundTrip(cf, req, argv.Interface()); err != nil {
+		return ErrNotLocal
+	}
+
+	if argIsValue {
+		argv = argv.Elem()
+	}
+
+	replyv := reflect.New(mtype.ReplyType.Elem())
+	r.rawBody = argv.Interface()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Error("panic recovered: ", rec)
+			log.Error(string(debug.Stack()))
+			err = merrors.InternalServerError("nitro", "panic recovered: %v", rec)
+		}
+	}()
+
+	function := mtype.method.Func
+
+	fn := func(ctx context.Context, req server.Request, rsp interface{}) error {
+		returnValues := function.Call(service.args(mtype.prepareContext(ctx), reflect.ValueOf(argv.Interface()), reflect.ValueOf(rsp)))
+
+		// The return value for the method is an error.
+		if err := returnValues[0].Interface(); err != nil {
+			return err.(error)
+		}
+
+		return nil
+	}
+
+	// wrap the handler
+	for i := len(router.hdlrWrappers); i > 0; i-- {
+		fn = router.hdlrWrappers[i-1](fn)
+	}
+
+	if err := fn(ctx, r, replyv.Interface()); err != nil {
+		return err
+	}
+
+	// decode the reply into the caller's response
+	if rsp != nil {
+		if err := roundTrip(cf, replyv.Interface(), rsp); err != nil {
+			return merrors.InternalServerError("nitro", "error decoding response: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// loopback is a buffer read back by the codec which wrote to it
+type loopback struct {
+	bytes.Buffer
+}
+
+func (l *loopback) Close() error {
+	return nil
+}
+
+// roundTrip encodes the value with the codec and decodes it into out as it
+// would be over the network, so out shares no memory with the value
+func roundTrip(cf codec.NewCodec, v, out interface{}) error {
+	buf := new(loopback)
+	c := cf(buf)
+
+	if err := c.Write(&codec.Message{Type: codec.Request, Id: "0"}, v); err != nil {
+		return err
+	}
+
+	// an empty body is decoded as an empty value
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	if err := c.ReadHeader(new(codec.Message), codec.Request); err != nil {
+		return err
+	}
+
+	return c.ReadBody(out)
+}
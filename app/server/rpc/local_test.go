@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gonitro/nitro/app/metadata"
+	"github.com/gonitro/nitro/app/network"
+	"github.com/gonitro/nitro/app/network/memory"
+	"github.com/gonitro/nitro/app/server"
+)
+
+type LocalRequest struct {
+	Name string
+	Tags []string
+}
+
+type LocalResponse struct {
+	Greeting string
+	Tags     []string
+}
+
+type Local struct{}
+
+func (l *Local) Greet(ctx context.Context, req *LocalRequest, rsp *LocalResponse) error {
+	md, _ := metadata.FromContext(ctx)
+	rsp.Greeting = md["Foo"] + " " + req.Name
+	rsp.Tags = req.Tags
+	// mutate the request which should not be seen by the caller
+	req.Tags[0] = "changed"
+	return nil
+}
+
+// callLocal serves the request with the server in this process at the address of the transport
+func callLocal(ctx context.Context, tr network.Transport, addr string, hdr map[string]string, req, rsp interface{}) error {
+	s, ok := server.LookupLocal(tr, addr)
+	if !ok {
+		return ErrNotLocal
+	}
+	return s.ServeLocal(ctx, hdr, req, rsp)
+}
+
+func TestServeLocal(t *testing.T) {
+	var wrapped int
+
+	s := NewServer(
+		server.Name("local"),
+		server.Address("127.0.0.1:0"),
+		server.WrapHandler(func(fn server.HandlerFunc) server.HandlerFunc {
+			return func(ctx context.Context, req server.Request, rsp interface{}) error {
+				wrapped++
+				return fn(ctx, req, rsp)
+			}
+		}),
+	)
+
+	if err := s.Handle(s.NewHandler(new(Local))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := s.Options().Address
+	hdr := map[string]string{
+		"App":      "local",
+		"Endpoint": "Local.Greet",
+		"Foo":      "Hello",
+	}
+
+	req := &LocalRequest{Name: "John", Tags: []string{"a", "b"}}
+	rsp := new(LocalResponse)
+
+	if err := callLocal(context.TODO(), s.Options().Transport, addr, hdr, req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Greeting != "Hello John" {
+		t.Fatalf("Expected greeting 'Hello John' got %q", rsp.Greeting)
+	}
+	if req.Tags[0] != "a" {
+		t.Fatalf("Expected request to be copied but it was changed to %v", req.Tags)
+	}
+	if rsp.Tags[0] != "changed" {
+		t.Fatalf("Expected response tags to be set got %v", rsp.Tags)
+	}
+	if wrapped != 1 {
+		t.Fatalf("Expected handler wrapper to be called once, called %d times", wrapped)
+	}
+
+	// the response is decoded as it would be over the network
+	m := make(map[string]interface{})
+	if err := callLocal(context.TODO(), s.Options().Transport, addr, hdr, req, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["Greeting"] != "Hello John" {
+		t.Fatalf("Expected greeting in the response map got %v", m)
+	}
+
+	// the server isn't reachable via another transport
+	if err := callLocal(context.TODO(), memory.NewTransport(), addr, hdr, req, rsp); err != ErrNotLocal {
+		t.Fatalf("Expected ErrNotLocal got %v", err)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// no longer served locally
+	if err := callLocal(context.TODO(), s.Options().Transport, addr, hdr, req, rsp); err != ErrNotLocal {
+		t.Fatalf("Expected ErrNotLocal got %v", err)
+	}
+}
+
+func TestServeLocalTransports(t *testing.T) {
+	// servers on separate transports can listen at the same address
+	var servers []server.Server
+
+	for _, name := range []string{"one", "two"} {
+		s := NewServer(
+			server.Name(name),
+			server.Address("127.0.0.1:8080"),
+			server.Transport(memory.NewTransport()),
+		)
+		if err := s.Handle(s.NewHandler(new(Local))); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Stop()
+
+		servers = append(servers, s)
+	}
+
+	for i, s := range servers {
+		hdr := map[string]string{
+			"App":      s.Options().Name,
+			"Endpoint": "Local.Greet",
+			"Foo":      s.Options().Name,
+		}
+		rsp := new(LocalResponse)
+
+		err := callLocal(context.TODO(), s.Options().Transport, "127.0.0.1:8080", hdr, &LocalRequest{Name: "John", Tags: []string{"a"}}, rsp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := s.Options().Name + " John"; rsp.Greeting != expect {
+			t.Fatalf("Expected server %d to reply %q got %q", i, expect, rsp.Greeting)
+		}
+	}
+}
+
+func TestServeHandler(t *testing.T) {
+	h := newRpcHandler(new(Local))
+
+	ctx := metadata.Set(context.TODO(), "Foo", "Hi")
+	req := &LocalRequest{Name: "Jane", Tags: []string{"a"}}
+	rsp := new(LocalResponse)
+
+	if err := ServeHandler(ctx, h, "Local.Greet", req, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Greeting != "Hi Jane" || req.Tags[0] != "a" {
+		t.Fatalf("Unexpected response %+v for request %+v", rsp, req)
+	}
+
+	if err := ServeHandler(ctx, h, "Local.Missing", req, rsp); err == nil {
+		t.Fatal("Expected error for missing endpoint")
+	}
+}
@@ -10,10 +10,11 @@ import (
 
 func newOptions(opt ...server.Option) server.Options {
 	opts := server.Options{
-		Codecs:      make(map[string]codec.NewCodec),
-		Metadata:    map[string]string{},
-		AddInterval: server.DefaultAddInterval,
-		AddTTL:      server.DefaultAddTTL,
+		Codecs:       make(map[string]codec.NewCodec),
+		Metadata:     map[string]string{},
+		AddInterval:  server.DefaultAddInterval,
+		AddTTL:       server.DefaultAddTTL,
+		DrainTimeout: server.DefaultDrainTimeout,
 	}
 
 	for _, o := range opt {
@@ -310,8 +310,9 @@ func (c *rpcCodec) Write(r *codec.Message, b interface{}) error {
 			return err
 		}
 	} else {
-		// set the body
-		body = c.buf.wbuf.Bytes()
+		// copy the body as the buffer is reused by the next write
+		// while the message may still be waiting to be sent
+		body = append([]byte(nil), c.buf.wbuf.Bytes()...)
 	}
 
 	// Set content type if theres content
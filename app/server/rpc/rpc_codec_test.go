@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/gonitro/nitro/app/codec"
+	"github.com/gonitro/nitro/app/codec/json"
 	"github.com/gonitro/nitro/app/network"
 )
 
@@ -64,6 +65,53 @@ func TestCodecWriteError(t *testing.T) {
 	}
 }
 
+// queueSocket keeps the messages sent like the pool socket
+// which queues them until they're sent over the connection
+type queueSocket struct {
+	testSocket
+	sent []*network.Message
+}
+
+func (s *queueSocket) Send(m *network.Message) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+// TestCodecWriteQueued checks the body of a message waiting to be sent
+// isn't overwritten by the next write, as happens when a stream sends
+// messages faster than they're written to the connection.
+func TestCodecWriteQueued(t *testing.T) {
+	socket := new(queueSocket)
+	message := network.Message{
+		Header: map[string]string{"Content-Type": "application/json"},
+	}
+
+	rwc := &readWriteCloser{
+		rbuf: new(bytes.Buffer),
+		wbuf: new(bytes.Buffer),
+	}
+
+	c := rpcCodec{
+		buf:    rwc,
+		codec:  json.NewCodec(rwc),
+		req:    &message,
+		socket: socket,
+	}
+
+	for _, body := range []string{"first", "second"} {
+		if err := c.Write(&codec.Message{Id: "0", Type: codec.Response}, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(socket.sent) != 2 {
+		t.Fatalf("Expected 2 messages sent got %d", len(socket.sent))
+	}
+	if body := string(socket.sent[0].Body); body != "\"first\"\n" {
+		t.Fatalf("Expected the first body to be kept got %q", body)
+	}
+}
+
 func (c *testCodec) ReadHeader(message *codec.Message, typ codec.MessageType) error {
 	return nil
 }
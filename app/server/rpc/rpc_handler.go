@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"reflect"
+	"strings"
 
 	"github.com/gonitro/nitro/app/registry"
 	"github.com/gonitro/nitro/app/server"
@@ -25,20 +26,36 @@ func newRpcHandler(handler interface{}, opts ...server.HandlerOption) server.Han
 
 	typ := reflect.TypeOf(handler)
 	hdlr := reflect.ValueOf(handler)
-	name := reflect.Indirect(hdlr).Type().Name()
 
+	var name string
 	var endpoints []*registry.Endpoint
 
-	for m := 0; m < typ.NumMethod(); m++ {
-		if e := extractEndpoint(typ.Method(m)); e != nil {
-			e.Name = name + "." + e.Name
+	if typ.Kind() == reflect.Func {
+		// a func is named by its endpoint e.g Greeter.Hello
+		parts := strings.SplitN(options.Endpoint, ".", 2)
+		name = parts[0]
 
+		if e := extractFuncEndpoint(options.Endpoint, typ); e != nil && len(parts) == 2 {
 			for k, v := range options.Metadata[e.Name] {
 				e.Metadata[k] = v
 			}
 
 			endpoints = append(endpoints, e)
 		}
+	} else {
+		name = reflect.Indirect(hdlr).Type().Name()
+
+		for m := 0; m < typ.NumMethod(); m++ {
+			if e := extractEndpoint(typ.Method(m)); e != nil {
+				e.Name = name + "." + e.Name
+
+				for k, v := range options.Metadata[e.Name] {
+					e.Metadata[k] = v
+				}
+
+				endpoints = append(endpoints, e)
+			}
+		}
 	}
 
 	return &rpcHandler{
@@ -74,6 +74,8 @@ type router struct {
 	hdlrWrappers []server.HandlerWrapper
 	// subscriber wrappers
 	subWrappers []server.SubscriberWrapper
+	// stream wrappers
+	streamWrappers []server.StreamWrapper
 
 	su          sync.RWMutex
 	subscribers map[string][]*subscriber
@@ -119,6 +121,12 @@ func isExportedOrBuiltinType(t reflect.Type) bool {
 // prepareMethod returns a methodType for the provided method or nil
 // in case if the method was unsuitable.
 func prepareMethod(method reflect.Method) *methodType {
+	return prepareFunc(method, 1)
+}
+
+// prepareFunc returns a methodType for a method with the given number of
+// receiver args, 1 for a method and 0 for a func, or nil if unsuitable.
+func prepareFunc(method reflect.Method, rcvr int) *methodType {
 	mtype := method.Type
 	mname := method.Name
 	var replyType, argType, contextType reflect.Type
@@ -129,17 +137,17 @@ func prepareMethod(method reflect.Method) *methodType {
 		return nil
 	}
 
-	switch mtype.NumIn() {
-	case 3:
+	switch mtype.NumIn() - rcvr {
+	case 2:
 		// assuming streaming
-		argType = mtype.In(2)
-		contextType = mtype.In(1)
+		argType = mtype.In(rcvr + 1)
+		contextType = mtype.In(rcvr)
 		stream = true
-	case 4:
+	case 3:
 		// method that takes a context
-		argType = mtype.In(2)
-		replyType = mtype.In(3)
-		contextType = mtype.In(1)
+		argType = mtype.In(rcvr + 1)
+		replyType = mtype.In(rcvr + 2)
+		contextType = mtype.In(rcvr)
 	default:
 		log.Errorf("method %v of %v has wrong number of ins: %v", mname, mtype, mtype.NumIn())
 		return nil
@@ -222,7 +230,7 @@ func (s *service) call(ctx context.Context, router *router, sending *sync.Mutex,
 
 	if !mtype.stream {
 		fn := func(ctx context.Context, req server.Request, rsp interface{}) error {
-			returnValues = function.Call([]reflect.Value{s.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(argv.Interface()), reflect.ValueOf(rsp)})
+			returnValues = function.Call(s.args(mtype.prepareContext(ctx), reflect.ValueOf(argv.Interface()), reflect.ValueOf(rsp)))
 
 			// The return value for the method is an error.
 			if err := returnValues[0].Interface(); err != nil {
@@ -258,7 +266,7 @@ func (s *service) call(ctx context.Context, router *router, sending *sync.Mutex,
 
 	// Invoke the method, providing a new value for the reply.
 	fn := func(ctx context.Context, req server.Request, stream interface{}) error {
-		returnValues = function.Call([]reflect.Value{s.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(stream)})
+		returnValues = function.Call(s.args(mtype.prepareContext(ctx), reflect.ValueOf(stream)))
 		if err := returnValues[0].Interface(); err != nil {
 			// the function returned an error, we use that
 			return err.(error)
@@ -279,7 +287,15 @@ func (s *service) call(ctx context.Context, router *router, sending *sync.Mutex,
 	r.stream = true
 
 	// execute handler
-	return fn(ctx, r, rawStream)
+	return fn(ctx, r, wrapStream(rawStream, router.streamWrappers))
+}
+
+// args prepends the receiver to the args unless the service is a func
+func (s *service) args(args ...reflect.Value) []reflect.Value {
+	if !s.rcvr.IsValid() {
+		return args
+	}
+	return append([]reflect.Value{s.rcvr}, args...)
 }
 
 func (m *methodType) prepareContext(ctx context.Context) reflect.Value {
@@ -423,6 +439,10 @@ func (router *router) Handle(h server.Handler) error {
 		router.serviceMap = make(map[string]*service)
 	}
 
+	if fn := reflect.ValueOf(h.Handler()); fn.Kind() == reflect.Func {
+		return router.handleFunc(h, fn)
+	}
+
 	if len(h.Name()) == 0 {
 		return errors.New("rpc.Handle: handler has no name")
 	}
@@ -461,6 +481,48 @@ func (router *router) Handle(h server.Handler) error {
 	return nil
 }
 
+// handleFunc adds a func handler as a method of the service named by its endpoint.
+// The caller must hold the lock.
+func (router *router) handleFunc(h server.Handler, fn reflect.Value) error {
+	endpoint := h.Options().Endpoint
+	if len(endpoint) == 0 {
+		return errors.New("rpc.Handle: func handler has no endpoint name")
+	}
+
+	parts := strings.Split(endpoint, ".")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return errors.New("rpc.Handle: func handler endpoint must be of the form Handler.Method: " + endpoint)
+	}
+	if !isExported(parts[0]) || !isExported(parts[1]) {
+		return errors.New("rpc.Handle: func handler endpoint " + endpoint + " is not exported")
+	}
+
+	mt := prepareFunc(reflect.Method{Name: parts[1], Type: fn.Type(), Func: fn}, 0)
+	if mt == nil {
+		return errors.New("rpc.Handle: func handler " + endpoint + " is not of suitable type")
+	}
+
+	s, ok := router.serviceMap[parts[0]]
+	if !ok {
+		s = &service{
+			name:   parts[0],
+			method: make(map[string]*methodType),
+		}
+	}
+
+	// funcs can only be added to services made of funcs
+	if s.rcvr.IsValid() {
+		return errors.New("rpc.Handle: service already defined: " + parts[0])
+	}
+	if _, present := s.method[parts[1]]; present {
+		return errors.New("rpc.Handle: endpoint already defined: " + endpoint)
+	}
+
+	s.method[parts[1]] = mt
+	router.serviceMap[s.name] = s
+	return nil
+}
+
 func (router *router) ServeRequest(ctx context.Context, r server.Request, rsp server.Response) error {
 	sending := new(sync.Mutex)
 	service, mtype, req, argv, replyv, keepReading, err := router.readRequest(r)
@@ -10,10 +10,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gonitro/nitro/app/codec"
 	raw "github.com/gonitro/nitro/app/codec/bytes"
+	mdebug "github.com/gonitro/nitro/app/debug"
 	"github.com/gonitro/nitro/app/event"
 	"github.com/gonitro/nitro/app/logger"
 	"github.com/gonitro/nitro/app/metadata"
@@ -40,8 +42,12 @@ type rpcServer struct {
 	registered bool
 	// subscribe to service name
 	subscriber event.Subscriber
-	// graceful exit
-	wg *sync.WaitGroup
+	// requests in flight to drain on exit
+	reqs *requests
+	// concurrency limits
+	limits *limits
+	// request stats for the debug handler
+	stats *mdebug.Stats
 
 	rsvc *registry.App
 }
@@ -50,6 +56,12 @@ var (
 	log = logger.NewHelper(logger.DefaultLogger).WithFields(map[string]interface{}{"service": "server"})
 )
 
+// appLog returns the server logger tagging entries with the app name so
+// the debug Log stream of each app in a process only returns its own
+func appLog(name string) *logger.Helper {
+	return log.WithFields(map[string]interface{}{mdebug.AppField: name})
+}
+
 func wait(ctx context.Context) *sync.WaitGroup {
 	if ctx == nil {
 		return nil
@@ -63,23 +75,51 @@ func wait(ctx context.Context) *sync.WaitGroup {
 
 func newServer(opts ...server.Option) server.Server {
 	options := newOptions(opts...)
+	stats := mdebug.NewStats()
 	router := newRpcRouter()
-	router.hdlrWrappers = options.HdlrWrappers
+	router.hdlrWrappers = handlerWrappers(options, stats)
 	router.subWrappers = options.SubWrappers
+	router.streamWrappers = options.StreamWrappers
 
-	return &rpcServer{
+	s := &rpcServer{
 		opts:        options,
 		router:      router,
 		handlers:    make(map[string]server.Handler),
 		subscribers: make(map[server.Subscriber][]event.Subscriber),
 		exit:        make(chan chan error),
-		wg:          wait(options.Context),
+		reqs:        newRequests(wait(options.Context)),
+		limits:      newLimits(options),
+		stats:       stats,
 	}
+
+	// register the internal debug handler
+	s.Handle(s.NewHandler(mdebug.NewHandler(s, stats), server.InternalHandler(true)))
+
+	return s
+}
+
+// handlerWrappers returns the wrappers applied to every handler. Stats are
+// recorded and access is verified before the wrappers set in the options.
+func handlerWrappers(opts server.Options, stats *mdebug.Stats) []server.HandlerWrapper {
+	wrappers := []server.HandlerWrapper{stats.Wrap}
+
+	if opts.Auth != nil || opts.Rules != nil {
+		wrappers = append(wrappers, authWrapper(opts))
+	}
+
+	return append(wrappers, opts.HdlrWrappers...)
 }
 
 // HandleEvent handles inbound messages to the service directly
 // TODO: handle requests from an event. We won't send a response.
 func (s *rpcServer) HandleEvent(msg *event.Message) error {
+	// track the event so we can drain on stop
+	done, ok := s.reqs.add()
+	if !ok {
+		return errStopping(s.Options().Name)
+	}
+	defer done()
+
 	if msg.Header == nil {
 		// create empty map in case of headers empty to avoid panic later
 		msg.Header = make(map[string]string)
@@ -123,16 +163,19 @@ func (s *rpcServer) HandleEvent(msg *event.Message) error {
 	}
 
 	// existing router
+	s.RLock()
 	r := server.Router(s.router)
+	opts := s.opts
+	s.RUnlock()
 
 	// if the router is present then execute it
-	if s.opts.Router != nil {
+	if opts.Router != nil {
 		// create a wrapped function
-		handler := s.opts.Router.ProcessMessage
+		handler := opts.Router.ProcessMessage
 
 		// execute the wrapper for it
-		for i := len(s.opts.SubWrappers); i > 0; i-- {
-			handler = s.opts.SubWrappers[i-1](handler)
+		for i := len(opts.SubWrappers); i > 0; i-- {
+			handler = opts.SubWrappers[i-1](handler)
 		}
 
 		// set the router
@@ -144,20 +187,32 @@ func (s *rpcServer) HandleEvent(msg *event.Message) error {
 
 // ServeConn serves a single connection
 func (s *rpcServer) ServeConn(sock network.Socket) {
+	log := appLog(s.Options().Name)
+
 	// streams are multiplexed on Stream or Id header
 	pool := socket.NewPool()
 
-	// get global waitgroup
-	s.Lock()
-	gg := s.wg
-	s.Unlock()
+	// responses from the multiplexed streams are sent concurrently
+	sock = &syncSocket{Socket: sock}
 
-	// waitgroup to wait for processing to finish
-	wg := &waitGroup{
-		gg: gg,
+	// cancels the requests in flight by id
+	cancels := newCancels()
+
+	// track the connection so it's closed on stop
+	if !s.reqs.addConn(sock) {
+		sock.Close()
+		return
 	}
 
+	// waitgroup to wait for processing to finish
+	wg := new(sync.WaitGroup)
+
 	defer func() {
+		s.reqs.removeConn(sock)
+
+		// the client has gone so stop processing its requests
+		cancels.cancelAll()
+
 		// wait till done
 		wg.Wait()
 
@@ -214,6 +269,12 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 			id = msg.Header["Id"]
 		}
 
+		// the client cancelled the request
+		if msg.Header["Control"] == "cancel" {
+			cancels.cancel(id)
+			continue
+		}
+
 		// check stream id
 		var stream bool
 
@@ -236,18 +297,12 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 
 		// got an existing socket already
 		if ok {
-			// we're starting processing
-			wg.Add(1)
-
 			// pass the message to that existing socket
 			if err := psock.Accept(&msg); err != nil {
 				// release the socket if there's an error
 				pool.Release(psock)
 			}
 
-			// done waiting
-			wg.Done()
-
 			// continue to the next message
 			continue
 		}
@@ -280,14 +335,16 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 		// create new context with the metadata
 		ctx := metadata.NewContext(context.Background(), hdr)
 
-		// set the timeout from the header if we have it
-		if len(to) > 0 {
-			if n, err := strconv.ParseUint(to, 10, 64); err == nil {
-				var cancel context.CancelFunc
-				ctx, cancel = context.WithTimeout(ctx, time.Duration(n))
-				defer cancel()
-			}
+		// set the timeout from the header if we have it. The context is
+		// cancelled when the request is done, when the client sends a cancel
+		// or when the connection closes.
+		var cancel context.CancelFunc
+		if n, err := strconv.ParseUint(to, 10, 64); err == nil && len(to) > 0 {
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(n))
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
 		}
+		cancels.add(id, cancel)
 
 		// if there's no content type default it
 		if len(ct) == 0 {
@@ -307,17 +364,42 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 				sock.Send(&network.Message{
 					Header: map[string]string{
 						"Content-Type": "text/plain",
+						"Id":           msg.Header["Id"],
 					},
 					Body: []byte(err.Error()),
 				})
 
 				// release the socket we just created
 				pool.Release(psock)
+				cancels.remove(id)
 				// now continue
 				continue
 			}
 		}
 
+		// reject new requests once the server is stopping
+		done, accepted := s.reqs.add()
+		if !accepted {
+			if err := newRpcCodec(&msg, sock, cf).Write(&codec.Message{
+				Header: msg.Header,
+				Error:  errStopping(s.Options().Name).Error(),
+				Type:   codec.Error,
+			}, nil); err != nil {
+				log.Debugf("rpc: unable to write error response: %v", err)
+			}
+			pool.Release(psock)
+			cancels.remove(id)
+			continue
+		}
+
+		// the request is done once the response is sent
+		var left int32 = 2
+		finish := func() {
+			if atomic.AddInt32(&left, -1) == 0 {
+				done()
+			}
+		}
+
 		// create a new rpc codec based on the pseudo socket and codec
 		rcodec := newRpcCodec(&msg, psock, cf)
 		// check the protocol as well
@@ -344,24 +426,47 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 		}
 
 		// set router
+		s.RLock()
 		r := server.Router(s.router)
+		opts := s.opts
+		lims := s.limits
+		s.RUnlock()
 
 		// if not nil use the router specified
-		if s.opts.Router != nil {
+		if opts.Router != nil {
 			// create a wrapped function
 			handler := func(ctx context.Context, req server.Request, rsp interface{}) error {
-				return s.opts.Router.ServeRequest(ctx, req, rsp.(server.Response))
+				return opts.Router.ServeRequest(ctx, req, rsp.(server.Response))
 			}
 
 			// execute the wrapper for it
-			for i := len(s.opts.HdlrWrappers); i > 0; i-- {
-				handler = s.opts.HdlrWrappers[i-1](handler)
+			wrappers := handlerWrappers(opts, s.stats)
+			for i := len(wrappers); i > 0; i-- {
+				handler = wrappers[i-1](handler)
 			}
 
 			// set the router
 			r = rpcRouter{h: handler}
 		}
 
+		// take a slot to execute the request before spawning any routines
+		release, acquired := lims.tryAcquire(request.endpoint)
+
+		// shed the request right away if there's no queueing
+		if !acquired && !lims.queues() {
+			if err := newRpcCodec(&msg, sock, cf).Write(&codec.Message{
+				Header: retryAfter(msg.Header, opts.RetryAfter),
+				Error:  lims.shed(request.endpoint).Error(),
+				Type:   codec.Error,
+			}, nil); err != nil {
+				log.Debugf("rpc: unable to write error response: %v", err)
+			}
+			pool.Release(psock)
+			cancels.remove(id)
+			done()
+			continue
+		}
+
 		// wait for two coroutines to exit
 		// serve the request and process the outbound messages
 		wg.Add(2)
@@ -378,6 +483,7 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 				// release the socket
 				pool.Release(psock)
 				// signal we're done
+				finish()
 				wg.Done()
 
 				// recover any panics for outbound process
@@ -406,9 +512,12 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 		// serve the request in a go routine as this may be a stream
 		go func(id string, psock *socket.Socket) {
 			defer func() {
+				// cancel the request context
+				cancels.remove(id)
 				// release the socket
 				pool.Release(psock)
 				// signal we're done
+				finish()
 				wg.Done()
 
 				// recover any panics for call handler
@@ -418,6 +527,23 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 				}
 			}()
 
+			// wait to be executed or shed the request
+			if !acquired {
+				var err error
+				if release, err = lims.acquire(ctx, request.endpoint); err != nil {
+					// ask the client to back off before retrying
+					if writeError := rcodec.Write(&codec.Message{
+						Header: retryAfter(msg.Header, opts.RetryAfter),
+						Error:  err.Error(),
+						Type:   codec.Error,
+					}, nil); writeError != nil {
+						log.Debugf("rpc: unable to write error response: %v", writeError)
+					}
+					return
+				}
+			}
+			defer release()
+
 			// serve the actual request using the request router
 			if serveRequestError := r.ServeRequest(ctx, request, response); serveRequestError != nil {
 				// write an error response
@@ -440,6 +566,68 @@ func (s *rpcServer) ServeConn(sock network.Socket) {
 	}
 }
 
+// cancels holds the cancel funcs of the requests in flight on a connection
+type cancels struct {
+	sync.Mutex
+	funcs map[string]context.CancelFunc
+}
+
+func newCancels() *cancels {
+	return &cancels{
+		funcs: make(map[string]context.CancelFunc),
+	}
+}
+
+func (c *cancels) add(id string, fn context.CancelFunc) {
+	c.Lock()
+	c.funcs[id] = fn
+	c.Unlock()
+}
+
+// cancel the request but leave it to be removed when done
+func (c *cancels) cancel(id string) {
+	c.Lock()
+	fn, ok := c.funcs[id]
+	c.Unlock()
+
+	if ok {
+		fn()
+	}
+}
+
+// remove cancels the request context and forgets it
+func (c *cancels) remove(id string) {
+	c.Lock()
+	fn, ok := c.funcs[id]
+	delete(c.funcs, id)
+	c.Unlock()
+
+	if ok {
+		fn()
+	}
+}
+
+func (c *cancels) cancelAll() {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, fn := range c.funcs {
+		fn()
+	}
+}
+
+// syncSocket serialises sends on a connection shared by many streams
+type syncSocket struct {
+	network.Socket
+	sync.Mutex
+}
+
+func (s *syncSocket) Send(m *network.Message) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.Socket.Send(m)
+}
+
 func (s *rpcServer) newCodec(contentType string) (codec.NewCodec, error) {
 	if cf, ok := s.opts.Codecs[contentType]; ok {
 		return cf, nil
@@ -464,12 +652,21 @@ func (s *rpcServer) Init(opts ...server.Option) error {
 	for _, opt := range opts {
 		opt(&s.opts)
 	}
+	// update the waitgroup if one was set
+	if wg := wait(s.opts.Context); wg != nil {
+		s.reqs.setWait(wg)
+	}
+	if s.limits.changed(s.opts) {
+		s.limits = newLimits(s.opts)
+	}
 	// update router if its the default
 	if s.opts.Router == nil {
 		r := newRpcRouter()
-		r.hdlrWrappers = s.opts.HdlrWrappers
+		r.hdlrWrappers = handlerWrappers(s.opts, s.stats)
 		r.serviceMap = s.router.serviceMap
+		r.subscribers = s.router.subscribers
 		r.subWrappers = s.opts.SubWrappers
+		r.streamWrappers = s.opts.StreamWrappers
 		s.router = r
 	}
 
@@ -490,11 +687,44 @@ func (s *rpcServer) Handle(h server.Handler) error {
 		return err
 	}
 
-	s.handlers[h.Name()] = h
+	// func handlers share a name so key them by endpoint
+	key := h.Name()
+	if ep := h.Options().Endpoint; len(ep) > 0 {
+		key = ep
+	}
+
+	s.handlers[key] = h
+
+	// the endpoints changed so re-create the registered app
+	s.rsvc = nil
 
 	return nil
 }
 
+// Handlers returns the handlers registered with the server
+func (s *rpcServer) Handlers() []server.Handler {
+	s.RLock()
+	defer s.RUnlock()
+
+	handlers := make([]server.Handler, 0, len(s.handlers))
+	for _, h := range s.handlers {
+		handlers = append(handlers, h)
+	}
+	return handlers
+}
+
+// Subscribers returns the subscribers registered with the server
+func (s *rpcServer) Subscribers() []server.Subscriber {
+	s.RLock()
+	defer s.RUnlock()
+
+	subscribers := make([]server.Subscriber, 0, len(s.subscribers))
+	for sb := range s.subscribers {
+		subscribers = append(subscribers, sb)
+	}
+	return subscribers
+}
+
 func (s *rpcServer) NewSubscriber(event string, sb interface{}, opts ...server.SubscriberOption) server.Subscriber {
 	return s.router.NewSubscriber(event, sb, opts...)
 }
@@ -508,6 +738,10 @@ func (s *rpcServer) Subscribe(sb server.Subscriber) error {
 	}
 
 	s.subscribers[sb] = nil
+
+	// the endpoints changed so re-create the registered app
+	s.rsvc = nil
+
 	return nil
 }
 
@@ -515,6 +749,7 @@ func (s *rpcServer) Add() error {
 	s.RLock()
 	rsvc := s.rsvc
 	config := s.Options()
+	log := appLog(config.Name)
 	s.RUnlock()
 
 	// only register if it exists or is not noop
@@ -724,6 +959,7 @@ func (s *rpcServer) Remove() error {
 
 	s.RLock()
 	config := s.Options()
+	log := appLog(config.Name)
 	s.RUnlock()
 
 	// only register if it exists or is not noop
@@ -817,6 +1053,10 @@ func (s *rpcServer) Start() error {
 	s.RUnlock()
 
 	config := s.Options()
+	log := appLog(config.Name)
+
+	// accept requests again if previously stopped
+	s.reqs.start()
 
 	// start listening on the network
 	ts, err := config.Transport.Listen(config.Address)
@@ -954,17 +1194,23 @@ func (s *rpcServer) Start() error {
 			}
 		}
 
-		s.Lock()
-		swg := s.wg
-		s.Unlock()
+		// stop serving in process requests
+		server.RemoveLocal(s)
+
+		// stop accepting new connections
+		err := ts.Close()
 
-		// wait for requests to finish
-		if swg != nil {
-			swg.Wait()
+		// wait for requests to finish and close the connections
+		if derr := s.reqs.drain(config.DrainTimeout); derr != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				log.Errorf("Server %s-%s %v", config.Name, config.Id, derr)
+			}
+			if err == nil {
+				err = derr
+			}
 		}
 
-		// close network listener
-		ch <- ts.Close()
+		ch <- err
 
 		if logger.V(logger.InfoLevel, logger.DefaultLogger) {
 			log.Infof("Broker [%s] Disconnected from %s", bname, config.Broker.Address())
@@ -985,8 +1231,12 @@ func (s *rpcServer) Start() error {
 	// mark the server as started
 	s.Lock()
 	s.started = true
+	advt := s.opts.Advertise
 	s.Unlock()
 
+	// serve requests from within this process
+	server.AddLocal(config.Transport, s, ts.Addr(), addr, advt)
+
 	return nil
 }
 
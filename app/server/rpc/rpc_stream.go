@@ -103,3 +103,11 @@ func (r *rpcStream) Close() error {
 	r.closed = true
 	return r.codec.Close()
 }
+
+// wrapStream applies the stream wrappers to the stream. The first wrapper is the outermost.
+func wrapStream(s server.Stream, wrappers []server.StreamWrapper) server.Stream {
+	for i := len(wrappers); i > 0; i-- {
+		s = wrappers[i-1](s)
+	}
+	return s
+}
@@ -1,32 +1,146 @@
 package rpc
 
 import (
+	"fmt"
 	"sync"
+	"time"
+
+	"github.com/gonitro/nitro/app/errors"
+	"github.com/gonitro/nitro/app/network"
 )
 
-// waitgroup for global management of connections
-type waitGroup struct {
-	// local waitgroup
-	lg sync.WaitGroup
-	// global waitgroup
-	gg *sync.WaitGroup
+// errStopping is returned for requests received while the server is stopping
+func errStopping(name string) error {
+	return errors.AppUnavailable(name, "%s is stopping", name)
+}
+
+// requests tracks the requests in flight and the open connections
+// so they can be drained and closed when the server is stopped
+type requests struct {
+	sync.Mutex
+	// set once draining starts so new requests are rejected
+	stopping bool
+	// number of requests in flight
+	count int
+	// closed when the count drops to zero while draining
+	idle chan bool
+	// open connections
+	conns map[network.Socket]bool
+	// waitgroup set with server.Wait
+	wg *sync.WaitGroup
+}
+
+func newRequests(wg *sync.WaitGroup) *requests {
+	return &requests{
+		conns: make(map[network.Socket]bool),
+		wg:    wg,
+	}
+}
+
+// start accepting requests again after a stop
+func (r *requests) start() {
+	r.Lock()
+	r.stopping = false
+	r.Unlock()
+}
+
+// setWait sets the waitgroup requests are also added to
+func (r *requests) setWait(wg *sync.WaitGroup) {
+	r.Lock()
+	r.wg = wg
+	r.Unlock()
 }
 
-func (w *waitGroup) Add(i int) {
-	w.lg.Add(i)
-	if w.gg != nil {
-		w.gg.Add(i)
+// add a request in flight. The func returned is called when it's done.
+// False is returned if the server is stopping and the request is rejected.
+func (r *requests) add() (func(), bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.stopping {
+		return nil, false
+	}
+
+	r.count++
+
+	// done is called on the waitgroup the request was added to
+	wg := r.wg
+	if wg != nil {
+		wg.Add(1)
 	}
+
+	return func() {
+		if wg != nil {
+			wg.Done()
+		}
+
+		r.Lock()
+		r.count--
+		if r.count == 0 && r.idle != nil {
+			close(r.idle)
+			r.idle = nil
+		}
+		r.Unlock()
+	}, true
 }
 
-func (w *waitGroup) Done() {
-	w.lg.Done()
-	if w.gg != nil {
-		w.gg.Done()
+// addConn tracks an open connection. False is returned if the server is stopping.
+func (r *requests) addConn(sock network.Socket) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.stopping {
+		return false
 	}
+
+	r.conns[sock] = true
+	return true
 }
 
-func (w *waitGroup) Wait() {
-	// only wait on local group
-	w.lg.Wait()
+func (r *requests) removeConn(sock network.Socket) {
+	r.Lock()
+	delete(r.conns, sock)
+	r.Unlock()
+}
+
+// drain rejects new requests and waits for those in flight to finish or the
+// timeout to expire. A zero timeout waits until they finish. The connections
+// still open are closed once done.
+func (r *requests) drain(timeout time.Duration) error {
+	r.Lock()
+	r.stopping = true
+	var idle chan bool
+	if r.count > 0 {
+		if r.idle == nil {
+			r.idle = make(chan bool)
+		}
+		idle = r.idle
+	}
+	r.Unlock()
+
+	var err error
+
+	if idle != nil && timeout <= time.Duration(0) {
+		<-idle
+	} else if idle != nil {
+		t := time.NewTimer(timeout)
+		select {
+		case <-idle:
+		case <-t.C:
+			err = fmt.Errorf("drain timeout after %v waiting for requests", timeout)
+		}
+		t.Stop()
+	}
+
+	r.Lock()
+	conns := r.conns
+	r.conns = make(map[network.Socket]bool)
+	r.Unlock()
+
+	// closing the connections cancels any requests left
+	for sock := range conns {
+		sock.Close()
+	}
+
+	return err
 }
@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gonitro/nitro/app/network"
+)
+
+// closeSocket records when it's closed
+type closeSocket struct {
+	network.Socket
+	closed chan bool
+}
+
+func (s *closeSocket) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestRequestsDrain(t *testing.T) {
+	reqs := newRequests(nil)
+
+	sock := &closeSocket{closed: make(chan bool)}
+	if !reqs.addConn(sock) {
+		t.Fatal("Expected connection to be accepted")
+	}
+
+	done, ok := reqs.add()
+	if !ok {
+		t.Fatal("Expected request to be accepted")
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- reqs.drain(time.Second * 5)
+	}()
+
+	// wait for the drain to start
+	for {
+		d, ok := reqs.add()
+		if !ok {
+			break
+		}
+		d()
+		time.Sleep(time.Millisecond)
+	}
+
+	if reqs.addConn(&closeSocket{closed: make(chan bool)}) {
+		t.Fatal("Expected connection to be rejected while draining")
+	}
+
+	select {
+	case <-sock.closed:
+		t.Fatal("Expected connection to stay open while requests are in flight")
+	case <-errCh:
+		t.Fatal("Expected drain to wait for requests in flight")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	done()
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("Expected nil error got %v", err)
+	}
+
+	select {
+	case <-sock.closed:
+	default:
+		t.Fatal("Expected connection to be closed after the drain")
+	}
+
+	// accept requests once started again
+	reqs.start()
+	if _, ok := reqs.add(); !ok {
+		t.Fatal("Expected request to be accepted after start")
+	}
+}
+
+func TestRequestsDrainTimeout(t *testing.T) {
+	reqs := newRequests(nil)
+
+	sock := &closeSocket{closed: make(chan bool)}
+	reqs.addConn(sock)
+	reqs.add()
+
+	if err := reqs.drain(time.Millisecond * 10); err == nil {
+		t.Fatal("Expected drain timeout error got nil")
+	}
+
+	select {
+	case <-sock.closed:
+	default:
+		t.Fatal("Expected connection to be closed after the drain timeout")
+	}
+}
@@ -133,11 +133,16 @@ type Subscriber interface {
 type Option func(*Options)
 
 var (
-	DefaultAddress     = "unix:///tmp/nitro.sock"
-	DefaultName        = "nitro"
-	DefaultVersion     = "latest"
-	DefaultId          = uuid.New().String()
-	DefaultAddCheck    = func(context.Context) error { return nil }
-	DefaultAddInterval = time.Second * 30
-	DefaultAddTTL      = time.Second * 90
+	DefaultAddress      = "unix:///tmp/nitro.sock"
+	DefaultName         = "nitro"
+	DefaultVersion      = "latest"
+	DefaultId           = uuid.New().String()
+	DefaultAddCheck     = func(context.Context) error { return nil }
+	DefaultAddInterval  = time.Second * 30
+	DefaultAddTTL       = time.Second * 90
+	DefaultDrainTimeout = time.Second * 30
+	// DefaultRetryAfter is the default time a client is asked to wait before retrying a shed request
+	DefaultRetryAfter = time.Millisecond * 100
+	// RetryAfterHeader is the response header holding the time to wait before retrying e.g 100ms
+	RetryAfterHeader = "Retry-After"
 )